@@ -0,0 +1,70 @@
+package cmd
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"errors"
+	"fmt"
+
+	"go-check-network-interface/file"
+	"go-check-network-interface/ifcheck"
+	"go-check-network-interface/netint"
+	"go-check-network-interface/snmp"
+	"go-check-network-interface/ui"
+
+	sknchk "github.com/pandaoc-io/go-shinken-check"
+)
+
+//exitOnError exit the check with the state matching the type of the error.
+//A missing interface is a Critical state, all the other errors (timeout, authentication, corrupted state...)
+//mean that the check can't determine the interface state and exit with an Unknown state.
+func exitOnError(err error) {
+	msg := fmt.Sprint(err)
+
+	var timeoutErr *snmp.TimeoutError
+	var authErr *snmp.AuthError
+	var noSuchIntErr *netint.NoSuchInterfaceError
+	var corruptedErr *file.StateCorruptedError
+	var deadlineErr *ifcheck.DeadlineError
+	switch {
+	case errors.As(err, &deadlineErr):
+		//The partial datas are displayed to help finding the slow part of the check
+		chk := &sknchk.Check{}
+		chk.AddShort(fmt.Sprintf("Check timeout reached during the %v phase", deadlineErr.Phase), false)
+		chk.AddUnknown()
+		for _, networkinterface := range deadlineErr.Interfaces {
+			status := "N/A"
+			if networkinterface.IfOperStatus != nil {
+				status = netint.OperToString(*networkinterface.IfOperStatus)
+			}
+			chk.AddLong(ui.InterfaceSummary(networkinterface, status), true)
+		}
+		sknchk.Exit(chk)
+	case errors.As(err, &noSuchIntErr):
+		sknchk.Critical(msg, "")
+	case errors.As(err, &timeoutErr):
+		sknchk.Unknown(fmt.Sprintf("SNMP timeout, check the reachability of the device and the SNMP configuration. %v", msg), "")
+	case errors.As(err, &authErr):
+		sknchk.Unknown(fmt.Sprintf("SNMP authentication failure, check the credentials. %v", msg), "")
+	case errors.As(err, &corruptedErr):
+		sknchk.Unknown(fmt.Sprintf("Corrupted state file, the datas have been reinitialised. %v", msg), "")
+	default:
+		sknchk.Unknown(msg, "")
+	}
+}
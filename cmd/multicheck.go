@@ -0,0 +1,36 @@
+package cmd
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+
+	"go-check-network-interface/ifcheck"
+
+	sknchk "github.com/pandaoc-io/go-shinken-check"
+)
+
+//multiInterfaceCheck check all the interfaces selected with the --interfaces or --interfaces-regex flags in a single run.
+func multiInterfaceCheck(ctx context.Context, opts *ifcheck.Options) {
+	result, err := ifcheck.CheckAll(ctx, opts)
+	if err != nil {
+		exitOnError(err)
+	}
+	sknchk.Exit(result.Check)
+}
@@ -21,6 +21,11 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 import (
 	"fmt"
 	"os"
+	"strings"
+
+	"go-check-network-interface/file"
+	"go-check-network-interface/netint"
+	"go-check-network-interface/ui"
 
 	"github.com/spf13/cobra"
 )
@@ -35,10 +40,14 @@ It check the interface oper/admin status and all the stats below :
 - In/Out Errors in pps/%
 - In/Out Discards in pps/%
 - Half/Full duplex
+- Ethernet errors and collisions (EtherLike-MIB), duplex mismatch
+- Light levels, temperature and bias current of the optical transceivers (DOM)
+- Active members, capacity and load balancing of the link aggregations (LAG, bond)
 
 It will also grap some additional informations like:
 - Speed of the interface (Mb)
 - Interface description also know as Alias
+- Stack of the subinterfaces down to their physical parent (ifStackTable)
 - Nb of pps per flow type in In/Out (Unicast/Multicast/Broadcast)
 `,
 }
@@ -54,20 +63,87 @@ func Execute() {
 
 func init() {
 	rootCmd.Version = "0.1"
+	file.ToolVersion = rootCmd.Version
 	rootCmd.PersistentFlags().StringP("hostname", "H", "127.0.0.1", "IP address or FQDN on which poll the information")
 	rootCmd.PersistentFlags().StringP("interface", "i", "lo", "Interface name on which to grap the information (required)")
+	rootCmd.PersistentFlags().String("interfaces", "", "Comma separated list of interface names to check in a single run, or 'all' to check all the interfaces of the device")
+	rootCmd.PersistentFlags().String("interfaces-regex", "", "Regular expression on the attribute given by --match-by to select the interfaces to check in a single run")
+	rootCmd.PersistentFlags().String("match-by", "name", "Attribute used to select the interface (name|descr|alias|index|mac), name match the ifName or the ifDescr")
+	rootCmd.PersistentFlags().String("match-type", "exact", "Type of matching used to select the interface (exact|prefix|regex)")
+	rootCmd.PersistentFlags().IntP("port", "p", 161, "Port of the SNMP agent")
+	rootCmd.PersistentFlags().String("transport", "udp", "Transport protocol used to reach the SNMP agent (udp|tcp|udp6|tcp6)")
+	rootCmd.PersistentFlags().String("source-address", "", "Local IP address used as source of the SNMP requests (udp and udp6 transports only)")
 	rootCmd.PersistentFlags().IntP("timeout", "t", 5, "Timeout of the SNMP requests")
 	rootCmd.PersistentFlags().IntP("retry", "r", 3, "Number of retry of the SNMP requests")
+	rootCmd.PersistentFlags().Int("check-timeout", 0, "Global timeout of the check in seconds, an Unknown state is returned with the datas already gathered when reached (0 to disable)")
+	rootCmd.PersistentFlags().Int("max-oids", 60, "Maximum number of OIDs sent in a single SNMP Get request")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Activate the verbose mode to display more debuging information")
 
-	rootCmd.PersistentFlags().String("bandwidth-warning", "80%", "Warning threshold of the Bandwidth usage (in %%)")
-	rootCmd.PersistentFlags().String("bandwidth-critical", "90%", "Critical threshold of the Bandwidth usage (in %%)")
+	rootCmd.PersistentFlags().String("bandwidth-warning", "80%", "Warning threshold of the Bandwidth usage (Nagios range in %% or bps with an optional k/M/G/T multiplier like 150Mbps, :<duration> suffix to alert only if sustained, ex: 80%%, 10%%: for a low usage, 80%%:15m)")
+	rootCmd.PersistentFlags().String("bandwidth-critical", "90%", "Critical threshold of the Bandwidth usage (Nagios range in %% or bps with an optional k/M/G/T multiplier like 150Mbps, :<duration> suffix to alert only if sustained, ex: 80%%, 10%%: for a low usage, 80%%:15m)")
+	rootCmd.PersistentFlags().String("bandwidth-in-warning", "", "Warning threshold of the In Bandwidth usage, same format as --bandwidth-warning which is used when not set")
+	rootCmd.PersistentFlags().String("bandwidth-in-critical", "", "Critical threshold of the In Bandwidth usage, same format as --bandwidth-critical which is used when not set")
+	rootCmd.PersistentFlags().String("bandwidth-out-warning", "", "Warning threshold of the Out Bandwidth usage, same format as --bandwidth-warning which is used when not set")
+	rootCmd.PersistentFlags().String("bandwidth-out-critical", "", "Critical threshold of the Out Bandwidth usage, same format as --bandwidth-critical which is used when not set")
+	rootCmd.PersistentFlags().String("speed-override", "", "Speed used instead of the speed reported by the device for the bandwidth usage and the perfdata max values, ex: 200M, or in/out speeds like 10M/1M")
+
+	rootCmd.PersistentFlags().String("checks", strings.Join(ui.DefaultChecks, ","), "Comma separated list of the metrics tested against their thresholds (bandwidth,errors,discards,flaps,optics,lag), the other ones are only displayed. The optical transceivers are only polled if optics is given")
+	rootCmd.PersistentFlags().String("config", "", "YAML file of the profiles setting the thresholds, the speed override and the checks of the interfaces matching them, the flags given on the command line override the profile")
+
+	rootCmd.PersistentFlags().String("admin-down", "ok", "State of the check when the interface is administratively down (ok|warning|critical|unknown)")
+	rootCmd.PersistentFlags().String("oper-down", "critical", "State of the check when the interface is administratively up and its oper status is down (ok|warning|critical|unknown)")
+	rootCmd.PersistentFlags().String("testing", "critical", "State of the check when the oper status of the interface is testing (ok|warning|critical|unknown)")
+	rootCmd.PersistentFlags().String("oper-unknown", "critical", "State of the check when the oper status of the interface is unknown (ok|warning|critical|unknown)")
+	rootCmd.PersistentFlags().String("dormant", "critical", "State of the check when the oper status of the interface is dormant (ok|warning|critical|unknown)")
+	rootCmd.PersistentFlags().String("not-present", "critical", "State of the check when the oper status of the interface is notPresent (ok|warning|critical|unknown)")
+	rootCmd.PersistentFlags().String("lower-layer-down", "critical", "State of the check when the oper status of the interface is lowerLayerDown (ok|warning|critical|unknown)")
+	rootCmd.PersistentFlags().Bool("expect-down", false, "The interface is expected to be down, the check is OK when it's down and Critical when it's UP")
+
+	rootCmd.PersistentFlags().String("error-warning", "50pps", "Warning threshold of the Errors (Nagios range in %% or pps, :<duration> suffix to alert only if sustained, ex: 50pps, @10:20pps, 50pps:10m)")
+	rootCmd.PersistentFlags().String("error-critical", "100pps", "Critical threshold of the Errors (Nagios range in %% or pps, :<duration> suffix to alert only if sustained, ex: 50pps, @10:20pps, 50pps:10m)")
+	rootCmd.PersistentFlags().String("error-in-warning", "", "Warning threshold of the In Errors, same format as --error-warning which is used when not set")
+	rootCmd.PersistentFlags().String("error-in-critical", "", "Critical threshold of the In Errors, same format as --error-critical which is used when not set")
+	rootCmd.PersistentFlags().String("error-out-warning", "", "Warning threshold of the Out Errors, same format as --error-warning which is used when not set")
+	rootCmd.PersistentFlags().String("error-out-critical", "", "Critical threshold of the Out Errors, same format as --error-critical which is used when not set")
+
+	rootCmd.PersistentFlags().String("dot3-error-warning", "", "Warning threshold of each error counter of the EtherLike-MIB (FCS, alignment, frame too long, internal MAC, symbol, carrier sense), same format as --error-warning with pps by default, not tested if not set")
+	rootCmd.PersistentFlags().String("dot3-error-critical", "", "Critical threshold of each error counter of the EtherLike-MIB (FCS, alignment, frame too long, internal MAC, symbol, carrier sense), same format as --error-critical with pps by default, not tested if not set")
 
-	rootCmd.PersistentFlags().String("error-warning", "50pps", "Warning threshold of the Errors (in %% or pps)")
-	rootCmd.PersistentFlags().String("error-critical", "100pps", "Critical threshold of the Errors (in %% or pps)")
+	rootCmd.PersistentFlags().String("collision-warning", "", "Warning threshold of each collision counter of the EtherLike-MIB (single, multiple, late, excessive, deferred), same format as --dot3-error-warning, not tested if not set")
+	rootCmd.PersistentFlags().String("collision-critical", "", "Critical threshold of each collision counter of the EtherLike-MIB (single, multiple, late, excessive, deferred), same format as --dot3-error-critical, not tested if not set")
+	rootCmd.PersistentFlags().String("duplex-mismatch", "warning", "State of the check when a duplex mismatch is suspected from the duplex mode, the late collisions and the FCS errors (ok|warning|critical|unknown)")
 
-	rootCmd.PersistentFlags().String("discard-warning", "50pps", "Warning threshold of the Bandwidth usage (in %% or pps)")
-	rootCmd.PersistentFlags().String("discard-critical", "100pps", "Critical threshold of the Bandwidth usage(in %% or pps)")
+	rootCmd.PersistentFlags().String("discard-warning", "50pps", "Warning threshold of the Discards (Nagios range in %% or pps, :<duration> suffix to alert only if sustained, ex: 50pps, @10:20pps, 50pps:10m)")
+	rootCmd.PersistentFlags().String("discard-critical", "100pps", "Critical threshold of the Discards (Nagios range in %% or pps, :<duration> suffix to alert only if sustained, ex: 50pps, @10:20pps, 50pps:10m)")
+	rootCmd.PersistentFlags().String("discard-in-warning", "", "Warning threshold of the In Discards, same format as --discard-warning which is used when not set")
+	rootCmd.PersistentFlags().String("discard-in-critical", "", "Critical threshold of the In Discards, same format as --discard-critical which is used when not set")
+	rootCmd.PersistentFlags().String("discard-out-warning", "", "Warning threshold of the Out Discards, same format as --discard-warning which is used when not set")
+	rootCmd.PersistentFlags().String("discard-out-critical", "", "Critical threshold of the Out Discards, same format as --discard-critical which is used when not set")
+
+	rootCmd.PersistentFlags().String("rx-power-warning", "", "Warning threshold of the Rx light level of the optical transceiver (Nagios range in dBm, ex: -14:2dBm), the thresholds of the transceiver are used if not set")
+	rootCmd.PersistentFlags().String("rx-power-critical", "", "Critical threshold of the Rx light level of the optical transceiver (Nagios range in dBm, ex: -16:3dBm), the thresholds of the transceiver are used if not set")
+	rootCmd.PersistentFlags().String("tx-power-warning", "", "Warning threshold of the Tx light level of the optical transceiver (Nagios range in dBm, ex: -7:2dBm), the thresholds of the transceiver are used if not set")
+	rootCmd.PersistentFlags().String("tx-power-critical", "", "Critical threshold of the Tx light level of the optical transceiver (Nagios range in dBm, ex: -9:3dBm), the thresholds of the transceiver are used if not set")
+	rootCmd.PersistentFlags().String("optics-temperature-warning", "", "Warning threshold of the temperature of the optical transceiver (Nagios range in celsius, ex: 70C), the thresholds of the transceiver are used if not set")
+	rootCmd.PersistentFlags().String("optics-temperature-critical", "", "Critical threshold of the temperature of the optical transceiver (Nagios range in celsius, ex: 75C), the thresholds of the transceiver are used if not set")
+
+	rootCmd.PersistentFlags().String("lag-capacity-warning", "100:", "Warning threshold of the capacity of the active members of a link aggregation in %% of the capacity of all its members (Nagios range, ex: 100: to alert when a member is inactive)")
+	rootCmd.PersistentFlags().String("lag-capacity-critical", "50:", "Critical threshold of the capacity of the active members of a link aggregation in %% of the capacity of all its members (Nagios range, ex: 50:)")
+	rootCmd.PersistentFlags().String("lag-unbalance-warning", "", "Warning threshold of the difference of usage in %% between the most and the least loaded active members of a link aggregation (Nagios range, :<duration> suffix to alert only if sustained, ex: 30:15m), not tested if not set")
+	rootCmd.PersistentFlags().String("lag-unbalance-critical", "", "Critical threshold of the difference of usage in %% between the most and the least loaded active members of a link aggregation (Nagios range, :<duration> suffix to alert only if sustained, ex: 50:15m), not tested if not set")
+
+	rootCmd.PersistentFlags().String("flap-warning", "3", "Warning threshold of the number of pollings with a status change of the interface (ifLastChange) in the history (Nagios range, :<duration> suffix to alert only if sustained)")
+	rootCmd.PersistentFlags().String("flap-critical", "6", "Critical threshold of the number of pollings with a status change of the interface (ifLastChange) in the history (Nagios range, :<duration> suffix to alert only if sustained)")
+	rootCmd.PersistentFlags().Duration("grace-period", 0, "Time after a status change of the interface during which the errors and discards aren't alerted, ex: 10m (0 to disable)")
 
 	rootCmd.PersistentFlags().Int("index-expiration", 60, "Expiration of the interfaces index file.")
+	rootCmd.PersistentFlags().Int("history-size", netint.DefaultHistorySize, "Maximum number of samples kept in the history of each interface")
+	rootCmd.PersistentFlags().Duration("history-window", 0, "Maximum age of the samples kept in the history of each interface, ex: 15m (0 to only limit the number of samples)")
+	rootCmd.PersistentFlags().String("evaluation", "last", "Value of the history tested against the bandwidth, error and discard thresholds (last|avg|max|p95)")
+
+	rootCmd.PersistentFlags().String("state-backend", "file", "Storage of the datas kept between 2 checks (file|bolt|redis), use redis when the checks of a device are load-balanced between several pollers")
+	rootCmd.PersistentFlags().String("state-dir", file.CheckPath, "Directory of the state files (file backend) or of the state database (bolt backend)")
+	rootCmd.PersistentFlags().String("state-redis-address", "127.0.0.1:6379", "Address of the Redis server used by the redis backend (host:port)")
+	rootCmd.PersistentFlags().String("state-redis-password", "", "Password of the Redis server used by the redis backend")
+	rootCmd.PersistentFlags().Int("state-redis-db", 0, "Database of the Redis server used by the redis backend")
 }
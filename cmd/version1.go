@@ -0,0 +1,41 @@
+package cmd
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// version1Cmd represents the version1 command
+var version1Cmd = &cobra.Command{
+	Use:   "version1",
+	Short: "SNMP request in version 1",
+	Long: `Poll the interface information in SNMP version 1.
+Only the 32 bits counters of the ifTable are available in this version, the ifXTable isn't polled.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		networkInterfaceCheck("1", cmd, args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(version1Cmd)
+
+	version1Cmd.Flags().StringP("community", "c", "public", "SNMP community used for polling (required)")
+	version1Cmd.MarkFlagRequired("community")
+}
@@ -40,6 +40,6 @@ func init() {
 	version3Cmd.Flags().StringP("auth-passphrase", "A", "passphrase", "Authentication passphrase.")
 	version3Cmd.Flags().StringP("sec-level", "l", "authPriv", "Security level (noAuthNoPriv|authNoPriv|authPriv).")
 	version3Cmd.Flags().StringP("context", "n", "", "Context name.")
-	version3Cmd.Flags().StringP("priv-protocol", "x", "AES", "Privacy protocol, can be in Upper or Lower case (DES|AES).")
+	version3Cmd.Flags().StringP("priv-protocol", "x", "AES", "Privacy protocol, can be in Upper or Lower case (DES|AES|AES-192|AES-256|AES-192C|AES-256C), the C suffix uses the Cisco key extension.")
 	version3Cmd.Flags().StringP("priv-passphrase", "X", "passphrase", "Privacy passphrase.")
 }
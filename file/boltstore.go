@@ -0,0 +1,127 @@
+package file
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+//BoltFilename is the name of the database of the bolt backend
+const BoltFilename = "state.db"
+
+//BoltStore store the states in a single BoltDB file, a bucket by device.
+//Each value is prefixed by its modification time (unix nanoseconds, 8 bytes big endian).
+//The database is only opened during a transaction, its file lock isn't held while the device is polled.
+type BoltStore struct {
+	Directory   string
+	LockTimeout time.Duration
+}
+
+//NewBoltStore return a BoltStore whose database is hosted in the given directory, the directory is created if needed
+func NewBoltStore(directory string, lockTimeout time.Duration) (*BoltStore, error) {
+	err := CreatePath(directory)
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStore{Directory: directory, LockTimeout: lockTimeout}, nil
+}
+
+//open open the database, in shared mode for the read-only accesses
+func (s *BoltStore) open(readOnly bool) (*bolt.DB, error) {
+	dbPath := path.Join(s.Directory, BoltFilename)
+	db, err := bolt.Open(dbPath, 0644, &bolt.Options{Timeout: s.LockTimeout, ReadOnly: readOnly})
+	if err != nil {
+		return nil, fmt.Errorf("Can't open the state database %v : %v", dbPath, err)
+	}
+	return db, nil
+}
+
+//Read return the content of the entry and its modification time
+func (s *BoltStore) Read(device string, name string) ([]byte, time.Time, error) {
+	//A read-only database can't be created
+	_, err := os.Stat(path.Join(s.Directory, BoltFilename))
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, ErrStateNotFound
+	}
+	db, err := s.open(true)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer db.Close()
+
+	var data []byte
+	var modTime time.Time
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(device))
+		if bucket == nil {
+			return ErrStateNotFound
+		}
+		value := bucket.Get([]byte(name))
+		if value == nil {
+			return ErrStateNotFound
+		}
+		if len(value) < 8 {
+			return &StateCorruptedError{Path: path.Join(db.Path(), device, name), Err: fmt.Errorf("missing modification time")}
+		}
+		modTime = time.Unix(0, int64(binary.BigEndian.Uint64(value[:8])))
+		//The value is only valid during the transaction
+		data = append([]byte(nil), value[8:]...)
+		return nil
+	})
+	return data, modTime, err
+}
+
+//Write create or replace the entry
+func (s *BoltStore) Write(device string, name string, data []byte) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(device))
+		if err != nil {
+			return err
+		}
+		value := make([]byte, 8, 8+len(data))
+		binary.BigEndian.PutUint64(value, uint64(time.Now().UnixNano()))
+		return bucket.Put([]byte(name), append(value, data...))
+	})
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+//Lock take an exclusive flock on the file name.lock of the device directory, next to the database.
+//The database lock only protects a single transaction, not the read-modify-write cycle of a check.
+func (s *BoltStore) Lock(ctx context.Context, device string, name string) (func() error, error) {
+	return flockEntry(ctx, s.Directory, device, name, s.LockTimeout)
+}
+
+//Close does nothing, the database is closed after each transaction
+func (s *BoltStore) Close() error {
+	return nil
+}
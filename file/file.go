@@ -21,24 +21,23 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"go-check-network-interface/netint"
+	"go-check-network-interface/snmp"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 	"golang.org/x/sys/unix"
 )
 
 //CheckPath is the global path where all the files read/write by the check will be hosted
 const CheckPath = "/var/tmp/go_check_snmp_interface_foreach"
 
-//DevicePath is the path where is will be stored all the JSON files of the device
-var DevicePath string
-
 //CreatePath will try to create the path give in argument.
 func CreatePath(fPath string) error {
 	fileInfo, err := os.Stat(fPath)
@@ -57,15 +56,14 @@ func CreatePath(fPath string) error {
 }
 
 //AsExp check if the file is older than the given expiration duration
-func AsExp(devicePath string, filename string, exptime time.Duration) (bool, error) {
+func AsExp(store StateStore, device string, filename string, exptime time.Duration) (bool, error) {
 	log.Debugln("===== File expiration check =====")
-	file, err := os.Stat(path.Join(devicePath, filename))
-	log.Debugf("Tested file : %v", path.Join(devicePath, filename))
+	_, modifiedtime, err := store.Read(device, filename)
+	log.Debugf("Tested file : %v", path.Join(device, filename))
 	if err != nil {
 		return false, err
 	}
 	log.Debugf("Expiration delay : %v", exptime)
-	modifiedtime := file.ModTime()
 	now := time.Now()
 	elapsed := now.Sub(modifiedtime)
 	log.Debugf("Last modification time : %s ago", elapsed)
@@ -78,53 +76,42 @@ func AsExp(devicePath string, filename string, exptime time.Duration) (bool, err
 }
 
 //CreateJSONFile will create a json file bases on the interface{} datas.
-func CreateJSONFile(devicePath string, filename string, datas interface{}) error {
+//The datas are stored in an envelope with the version of the format, the identity of the device and their checksum.
+func CreateJSONFile(store StateStore, device string, filename string, identity *netint.DeviceIdentity, datas interface{}) error {
 	log.Debugln("===== JSON file creation =====")
-	log.Debugf("File to create : %v", path.Join(devicePath, filename))
-	if _, err := os.Stat(path.Join(devicePath, filename)); err != nil {
-		err = CreatePath(devicePath)
-		if err != nil {
-			return err
-		}
-	}
-	datasBytes, err := json.MarshalIndent(datas, "", "    ")
+	log.Debugf("File to create : %v", path.Join(device, filename))
+	datasBytes, err := encodeState(identity, datas)
 	if err != nil {
 		return fmt.Errorf("Can't Marshal the datas : %v, Datas : %#v", err, datas)
 	}
 	jsonStr := string(datasBytes)
 	log.Debug("The JSON data is : ", jsonStr)
 
-	err = ioutil.WriteFile(path.Join(devicePath, filename), datasBytes, 0644)
-	if err != nil {
-		return fmt.Errorf("Can't create the file : %v", err)
-	}
-	return nil
+	return store.Write(device, filename, datasBytes)
 }
 
-//GenDeviceDirName will string the full path used to read/write the interface information
-//for a given device
-func GenDeviceDirName(version string, cmd *cobra.Command) string {
-	dIP, _ := cmd.Flags().GetString("hostname")
-	context, _ := cmd.Flags().GetString("context")
-	deviceDir := ""
-	if len(context) > 0 {
-		deviceDir = dIP + "_SNMPv" + version + "_" + context
-	} else {
-		deviceDir = dIP + "_SNMPv" + version
+//GenDeviceDirName will generate the name of the directory used to read/write the interface information
+//for a given device, it's also the name of the device in the other state backends
+func GenDeviceDirName(opts *snmp.Options) string {
+	deviceDir := opts.Target
+	//The default port and transport aren't added to keep the same directory as the previous versions
+	if opts.Port != 0 && opts.Port != 161 {
+		deviceDir += "_" + strconv.Itoa(opts.Port)
+	}
+	if opts.Transport != "" && opts.Transport != "udp" {
+		deviceDir += "_" + opts.Transport
 	}
-	return path.Join(CheckPath, deviceDir)
+	deviceDir += "_SNMPv" + opts.Version
+	if len(opts.Context) > 0 {
+		deviceDir += "_" + opts.Context
+	}
+	return deviceDir
 }
 
 //CheckFileExist will check if the file 'filename' exist
-func CheckFileExist(devicePath string, filename string) error {
-	fullPath := path.Join(devicePath, filename)
-	_, err := os.Stat(fullPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return err
-		}
-	}
-	return nil
+func CheckFileExist(store StateStore, device string, filename string) error {
+	_, _, err := store.Read(device, filename)
+	return err
 }
 
 //IsPathWritable check if the path is writable
@@ -137,43 +124,85 @@ func IsPathWritable(path string) error {
 	return nil
 }
 
+//AmbiguousIndexError is returned by FindIntIndex when several interfaces match the selection
+type AmbiguousIndexError struct {
+	Matcher    *netint.Matcher
+	Candidates []string
+}
+
+func (e *AmbiguousIndexError) Error() string {
+	return fmt.Sprintf("Several interfaces match the selection (%v) : %v", e.Matcher, strings.Join(e.Candidates, ", "))
+}
+
 //FindIntIndex read the index.json file and return the interface if found
-// and an error if not found or if issue reading the file
-func FindIntIndex(devicePath string, name string) (string, error) {
+// and an error if not found, if several interfaces are found or if issue reading the file
+func FindIntIndex(store StateStore, device string, identity *netint.DeviceIdentity, matcher *netint.Matcher) (string, error) {
 	log.Debugln("===== Search of the interface Index =====")
-	jsonFile, err := os.Open(path.Join(devicePath, "index.json"))
+	byteValue, err := readState(store, device, "index.json", identity)
 	if err != nil {
-		return "", fmt.Errorf("Find Interface Index: %v", err)
+		return "", fmt.Errorf("Find Interface Index: %w", err)
+	}
+	var indexMap map[int]map[string]string
+	err = json.Unmarshal(byteValue, &indexMap)
+	if err != nil {
+		return "", &StateCorruptedError{Path: path.Join(device, "index.json"), Err: err}
 	}
-	// defer the closing of our jsonFile so that we can parse it later on
-	defer jsonFile.Close()
-
-	byteValue, _ := ioutil.ReadAll(jsonFile)
-	var indexMap map[string]interface{}
-	json.Unmarshal([]byte(byteValue), &indexMap)
 
+	var indexes []int
 	for k, v := range indexMap {
-		value := v.(map[string]interface{})
-		if value["IfDescr"] == name || value["IfName"] == name {
-			log.Debugf("Interface Index found : %v", k)
-			return k, nil
+		if matcher.Match(k, v) {
+			indexes = append(indexes, k)
 		}
 	}
-	return "", fmt.Errorf("Index for interface %v not found", name)
+	sort.Ints(indexes)
+
+	switch len(indexes) {
+	case 0:
+		return "", &netint.NoSuchInterfaceError{Matcher: matcher}
+	case 1:
+		log.Debugf("Interface Index found : %v", indexes[0])
+		return strconv.Itoa(indexes[0]), nil
+	default:
+		var candidates []string
+		for _, k := range indexes {
+			candidate := fmt.Sprintf("%v (index %v", indexMap[k]["IfName"], k)
+			if len(indexMap[k]["IfDescr"]) > 0 {
+				candidate += ", descr '" + indexMap[k]["IfDescr"] + "'"
+			}
+			if len(indexMap[k]["IfAlias"]) > 0 {
+				candidate += ", alias '" + indexMap[k]["IfAlias"] + "'"
+			}
+			candidates = append(candidates, candidate+")")
+		}
+		return "", &AmbiguousIndexError{Matcher: matcher, Candidates: candidates}
+	}
 }
 
 //ReadJSONIntFile will read the interface values from the interface JSON file and generate the corresponding struct
-func ReadJSONIntFile(devicePath string, filename string) (interface{}, error) {
-	fullPath := path.Join(devicePath, filename)
-	jsonFile, err := os.Open(fullPath)
+func ReadJSONIntFile(store StateStore, device string, filename string, identity *netint.DeviceIdentity) (*netint.InterfaceDetails, error) {
+	fullPath := path.Join(device, filename)
+	byteValue, err := readState(store, device, filename, identity)
 	if err != nil {
-		return "", fmt.Errorf("Find Interface Index: %v", err)
+		return nil, fmt.Errorf("Read Interface file: %w", err)
+	}
+	elements := &netint.InterfaceDetails{}
+	err = json.Unmarshal(byteValue, elements)
+	if err != nil {
+		return nil, &StateCorruptedError{Path: fullPath, Err: err}
 	}
-	// defer the closing of our jsonFile so that we can parse it later on
-	defer jsonFile.Close()
-
-	byteValue, _ := ioutil.ReadAll(jsonFile)
-	var elements netint.InterfaceDetails
-	json.Unmarshal([]byte(byteValue), &elements)
 	return elements, nil
 }
+
+//StateCorruptedError is returned when a state file of the check can't be decoded
+type StateCorruptedError struct {
+	Path string
+	Err  error
+}
+
+func (e *StateCorruptedError) Error() string {
+	return fmt.Sprintf("The state file %v is corrupted : %v", e.Path, e.Err)
+}
+
+func (e *StateCorruptedError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,143 @@
+package file
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+//FSStore store the states as files, a directory by device
+type FSStore struct {
+	Directory   string
+	LockTimeout time.Duration
+}
+
+//NewFSStore return a FSStore hosted in the given directory, the directory is created if needed
+func NewFSStore(directory string, lockTimeout time.Duration) (*FSStore, error) {
+	err := CreatePath(directory)
+	if err != nil {
+		return nil, err
+	}
+	return &FSStore{Directory: directory, LockTimeout: lockTimeout}, nil
+}
+
+//Read return the content of the state file and its modification time
+func (s *FSStore) Read(device string, name string) ([]byte, time.Time, error) {
+	fullPath := path.Join(s.Directory, device, name)
+	fileInfo, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, ErrStateNotFound
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := ioutil.ReadFile(fullPath)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, fileInfo.ModTime(), nil
+}
+
+//Write create or replace the state file, the device directory is created if needed.
+//The datas are written in a temporary file renamed at the end, so a check killed in the middle
+//of the write never leaves a truncated state file.
+func (s *FSStore) Write(device string, name string, data []byte) error {
+	devicePath := path.Join(s.Directory, device)
+	log.Debugf("File to write : %v", path.Join(devicePath, name))
+	err := CreatePath(devicePath)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(devicePath, "."+name+".tmp")
+	if err != nil {
+		return fmt.Errorf("Can't create the file : %v", err)
+	}
+	//Nothing to remove once the file has been renamed
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpFile.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), path.Join(devicePath, name))
+	}
+	if err != nil {
+		return fmt.Errorf("Can't create the file : %v", err)
+	}
+	return nil
+}
+
+//Lock take an exclusive flock on the file name.lock of the device directory.
+//The lock is released by the kernel if the check is killed.
+func (s *FSStore) Lock(ctx context.Context, device string, name string) (func() error, error) {
+	return flockEntry(ctx, s.Directory, device, name, s.LockTimeout)
+}
+
+//flockEntry take an exclusive flock on the file name.lock of the device directory hosted in directory,
+//the lock is released by closing the file
+func flockEntry(ctx context.Context, directory string, device string, name string, timeout time.Duration) (func() error, error) {
+	devicePath := path.Join(directory, device)
+	err := CreatePath(devicePath)
+	if err != nil {
+		return nil, err
+	}
+	lockFile, err := os.OpenFile(path.Join(devicePath, name+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Can't create the lock file : %v", err)
+	}
+	err = waitLock(ctx, device, name, timeout, func() (bool, error) {
+		err := unix.Flock(int(lockFile.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == unix.EWOULDBLOCK || err == unix.EINTR {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("Can't lock the file %v : %v", lockFile.Name(), err)
+		}
+		return true, nil
+	})
+	if err != nil {
+		lockFile.Close()
+		return nil, err
+	}
+	log.Debugf("Lock taken : %v", lockFile.Name())
+	return func() error {
+		//Closing the file release the lock
+		return lockFile.Close()
+	}, nil
+}
+
+//Close does nothing, the files are closed after each access
+func (s *FSStore) Close() error {
+	return nil
+}
@@ -0,0 +1,208 @@
+package file
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//RedisKeyPrefix is the prefix of all the keys written by the redis backend
+const RedisKeyPrefix = "go_check_snmp_interface"
+
+//RedisStore store the states in a server speaking the Redis protocol (Redis, KeyDB, Valkey...),
+//so the checks of a device can be run by any poller.
+//Each entry is a hash with the fields 'data' and 'mtime' (unix nanoseconds).
+type RedisStore struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	timeout     time.Duration
+	lockTimeout time.Duration
+}
+
+//redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+//NewRedisStore connect to the server, authenticate and select the database if needed
+func NewRedisStore(address string, password string, db int, timeout time.Duration, lockTimeout time.Duration) (*RedisStore, error) {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("Can't connect to the state server %v : %v", address, err)
+	}
+	s := &RedisStore{conn: conn, reader: bufio.NewReader(conn), timeout: timeout, lockTimeout: lockTimeout}
+	if password != "" {
+		if _, err = s.do("AUTH", password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Can't authenticate on the state server %v : %v", address, err)
+		}
+	}
+	if db != 0 {
+		if _, err = s.do("SELECT", strconv.Itoa(db)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("Can't select the database %v on the state server %v : %v", db, address, err)
+		}
+	}
+	return s, nil
+}
+
+func redisKey(device string, name string) string {
+	return strings.Join([]string{RedisKeyPrefix, device, name}, ":")
+}
+
+//Read return the content of the entry and its modification time
+func (s *RedisStore) Read(device string, name string) ([]byte, time.Time, error) {
+	reply, err := s.do("HMGET", redisKey(device, name), "data", "mtime")
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	fields, ok := reply.([]interface{})
+	if !ok || len(fields) != 2 {
+		return nil, time.Time{}, fmt.Errorf("redis: unexpected reply to HMGET : %#v", reply)
+	}
+	if fields[0] == nil {
+		return nil, time.Time{}, ErrStateNotFound
+	}
+	data, _ := fields[0].([]byte)
+	mtime, _ := fields[1].([]byte)
+	nsec, err := strconv.ParseInt(string(mtime), 10, 64)
+	if err != nil {
+		return nil, time.Time{}, &StateCorruptedError{Path: redisKey(device, name), Err: err}
+	}
+	return data, time.Unix(0, nsec), nil
+}
+
+//Write create or replace the entry, both fields are set by a single command
+func (s *RedisStore) Write(device string, name string, data []byte) error {
+	_, err := s.do("HSET", redisKey(device, name), "data", string(data), "mtime", strconv.FormatInt(time.Now().UnixNano(), 10))
+	return err
+}
+
+//redisUnlockScript delete the lock only if it's still owned by the check, it could have expired and been taken by another one
+const redisUnlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+//Lock take the lock key name.lock of the device with a random token.
+//The lock expires after the lock timeout if the check is killed before releasing it.
+func (s *RedisStore) Lock(ctx context.Context, device string, name string) (func() error, error) {
+	lockKey := redisKey(device, name+".lock")
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(random)
+	ttl := strconv.FormatInt(int64(s.lockTimeout/time.Millisecond), 10)
+	err := waitLock(ctx, device, name, s.lockTimeout, func() (bool, error) {
+		reply, err := s.do("SET", lockKey, token, "NX", "PX", ttl)
+		return reply != nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := s.do("EVAL", redisUnlockScript, "1", lockKey, token)
+		return err
+	}, nil
+}
+
+//Close close the connection to the server
+func (s *RedisStore) Close() error {
+	return s.conn.Close()
+}
+
+//do send a command and return its reply: nil, string, int64, []byte or []interface{}
+func (s *RedisStore) do(args ...string) (interface{}, error) {
+	err := s.conn.SetDeadline(time.Now().Add(s.timeout))
+	if err != nil {
+		return nil, err
+	}
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err = io.WriteString(s.conn, cmd.String()); err != nil {
+		return nil, err
+	}
+	return s.readReply()
+}
+
+//readReply parse a reply of the server, see https://redis.io/docs/reference/protocol-spec/
+func (s *RedisStore) readReply() (interface{}, error) {
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(s.reader, buf); err != nil {
+			return nil, err
+		}
+		return buf[:size], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		elements := make([]interface{}, count)
+		for i := range elements {
+			if elements[i], err = s.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
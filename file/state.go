@@ -0,0 +1,164 @@
+package file
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"go-check-network-interface/netint"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//StateVersion is the version of the format of the state files written by the check
+const StateVersion = 2
+
+//ToolVersion is the version of the check written in the state files
+var ToolVersion = "dev"
+
+//stateEnvelope is the format of the state files.
+//The checksum of the sample detect a truncated or modified file, the identity of the device detect
+//a device replaced behind the same address.
+type stateEnvelope struct {
+	Version     int                    `json:"version"`
+	ToolVersion string                 `json:"tool_version"`
+	Device      *netint.DeviceIdentity `json:"device,omitempty"`
+	Checksum    string                 `json:"checksum"`
+	Sample      json.RawMessage        `json:"sample"`
+}
+
+//stateMigrations upgrade the fields of an envelope from a version (the key) to the next one
+var stateMigrations = map[int]func(fields map[string]json.RawMessage) error{
+	//Version 0 : the files of the first versions of the check only contain the datas, stored in 'data' by readState
+	0: func(fields map[string]json.RawMessage) error {
+		sum, err := checksum(fields["data"])
+		if err != nil {
+			return err
+		}
+		fields["checksum"], _ = json.Marshal(sum)
+		return nil
+	},
+	//Version 1 : the datas are stored in 'data', without identity of the device
+	1: func(fields map[string]json.RawMessage) error {
+		fields["sample"] = fields["data"]
+		delete(fields, "data")
+		return nil
+	},
+}
+
+//DeviceChangedError is returned when the state has been written for another device than the one polled
+type DeviceChangedError struct {
+	Path string
+	Old  *netint.DeviceIdentity
+	New  *netint.DeviceIdentity
+}
+
+func (e *DeviceChangedError) Error() string {
+	return fmt.Sprintf("The device has changed since the creation of %v (%v, now %v)", e.Path, e.Old, e.New)
+}
+
+//checksum return the sha256 of the compacted json datas, independent of the indentation of the file
+func checksum(data []byte) (string, error) {
+	var compacted bytes.Buffer
+	err := json.Compact(&compacted, data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(compacted.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
+
+//encodeState marshal the datas into a state envelope
+func encodeState(identity *netint.DeviceIdentity, datas interface{}) ([]byte, error) {
+	dataBytes, err := json.Marshal(datas)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := checksum(dataBytes)
+	if err != nil {
+		return nil, err
+	}
+	envelope := stateEnvelope{
+		Version:     StateVersion,
+		ToolVersion: ToolVersion,
+		Device:      identity,
+		Checksum:    sum,
+		Sample:      dataBytes,
+	}
+	return json.MarshalIndent(envelope, "", "    ")
+}
+
+//readState read the entry of the store, migrate it to the current version and return its sample
+//once the checksum and the identity of the device verified.
+func readState(store StateStore, device string, filename string, identity *netint.DeviceIdentity) ([]byte, error) {
+	fullPath := path.Join(device, filename)
+	content, _, err := store.Read(device, filename)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(content, &fields)
+	if err != nil {
+		return nil, &StateCorruptedError{Path: fullPath, Err: err}
+	}
+	version := 0
+	if rawVersion, ok := fields["version"]; ok {
+		err = json.Unmarshal(rawVersion, &version)
+		if err != nil {
+			return nil, &StateCorruptedError{Path: fullPath, Err: err}
+		}
+	} else {
+		fields = map[string]json.RawMessage{"data": content}
+	}
+	if version > StateVersion {
+		return nil, &StateCorruptedError{Path: fullPath, Err: fmt.Errorf("unsupported version %v, written by a newer version of the check", version)}
+	}
+	for ; version < StateVersion; version++ {
+		log.Debugf("Migration of %v from the version %v to %v", fullPath, version, version+1)
+		err = stateMigrations[version](fields)
+		if err != nil {
+			return nil, &StateCorruptedError{Path: fullPath, Err: fmt.Errorf("migration from the version %v : %v", version, err)}
+		}
+	}
+
+	var envelope stateEnvelope
+	migrated, err := json.Marshal(fields)
+	if err == nil {
+		err = json.Unmarshal(migrated, &envelope)
+	}
+	if err != nil {
+		return nil, &StateCorruptedError{Path: fullPath, Err: err}
+	}
+	sum, err := checksum(envelope.Sample)
+	if err != nil {
+		return nil, &StateCorruptedError{Path: fullPath, Err: err}
+	}
+	if sum != envelope.Checksum {
+		return nil, &StateCorruptedError{Path: fullPath, Err: fmt.Errorf("checksum mismatch")}
+	}
+	if envelope.Device.Differs(identity) {
+		return nil, &DeviceChangedError{Path: fullPath, Old: envelope.Device, New: identity}
+	}
+	return envelope.Sample, nil
+}
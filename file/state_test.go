@@ -0,0 +1,112 @@
+package file
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-check-network-interface/netint"
+)
+
+//sampleData is the content of an interface file of the first versions of the check
+const sampleData = `{
+    "Timestamp": 1600000000,
+    "Index": 3,
+    "IfName": "Gi0/1",
+    "IfHCInOctets": 123456789
+}`
+
+func TestReadState(t *testing.T) {
+	identity := &netint.DeviceIdentity{SysObjectID: "1.3.6.1.4.1.9.1.1", EngineID: "800000090300aabbccddeeff"}
+	sum, err := checksum([]byte(sampleData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := &netint.InterfaceDetails{}
+	err = json.Unmarshal([]byte(sampleData), original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := encodeState(identity, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		content   string
+		corrupted bool
+		changed   bool
+	}{
+		{name: "version 0", content: sampleData},
+		{name: "version 1", content: fmt.Sprintf(`{"version": 1, "checksum": %q, "data": %v}`, sum, sampleData)},
+		{name: "version 2", content: string(current)},
+		{name: "version 0 truncated", content: sampleData[:40], corrupted: true},
+		{name: "version 1 checksum mismatch", content: fmt.Sprintf(`{"version": 1, "checksum": %q, "data": {"Index": 4}}`, sum), corrupted: true},
+		{name: "version 2 checksum mismatch", content: fmt.Sprintf(`{"version": 2, "checksum": %q, "sample": {"Index": 4}}`, sum), corrupted: true},
+		{name: "newer version", content: fmt.Sprintf(`{"version": 3, "checksum": %q, "sample": %v}`, sum, sampleData), corrupted: true},
+		{
+			name:    "device changed",
+			content: fmt.Sprintf(`{"version": 2, "device": {"sys_object_id": "1.3.6.1.4.1.2636.1.1"}, "checksum": %q, "sample": %v}`, sum, sampleData),
+			changed: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, err := NewFSStore(t.TempDir(), time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = store.Write("device", "Gi0_1.json", []byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sample, err := readState(store, "device", "Gi0_1.json", identity)
+			var corrupted *StateCorruptedError
+			var changed *DeviceChangedError
+			switch {
+			case test.corrupted:
+				if !errors.As(err, &corrupted) {
+					t.Fatalf("Expected a StateCorruptedError, got %v", err)
+				}
+			case test.changed:
+				if !errors.As(err, &changed) {
+					t.Fatalf("Expected a DeviceChangedError, got %v", err)
+				}
+			case err != nil:
+				t.Fatalf("Unexpected error : %v", err)
+			default:
+				migrated := &netint.InterfaceDetails{}
+				err = json.Unmarshal(sample, migrated)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(migrated, original) {
+					t.Errorf("The sample doesn't match the original datas : %s", sample)
+				}
+			}
+		})
+	}
+}
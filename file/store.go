@@ -0,0 +1,133 @@
+package file
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//Backends of the state storage
+const (
+	BackendFile  = "file"
+	BackendBolt  = "bolt"
+	BackendRedis = "redis"
+)
+
+//ErrStateNotFound is returned by a StateStore when the requested entry doesn't exist
+var ErrStateNotFound = errors.New("state not found")
+
+//DefaultLockTimeout is the maximum wait of the lock of an entry held by another check
+const DefaultLockTimeout = 5 * time.Second
+
+//StateStore is the storage of the datas kept between 2 checks of a device (index and interfaces states).
+//The entries are grouped by device, the name of the entry is unique for a given device.
+type StateStore interface {
+	//Read return the content of the entry and its last modification time
+	Read(device string, name string) ([]byte, time.Time, error)
+	//Write create or replace the entry, a reader never see a partially written entry
+	Write(device string, name string, data []byte) error
+	//Lock take the exclusive lock of the entry to protect its read-modify-write cycle against the concurrent checks,
+	//the returned function release the lock. The wait ends with the lock timeout or the context, whichever comes first.
+	Lock(ctx context.Context, device string, name string) (func() error, error)
+	Close() error
+}
+
+//LockError is returned when the lock of an entry can't be taken before the end of the lock timeout
+type LockError struct {
+	Device  string
+	Name    string
+	Timeout time.Duration
+}
+
+func (e *LockError) Error() string {
+	return fmt.Sprintf("The state %v of %v is locked by another check since more than %v", e.Name, e.Device, e.Timeout)
+}
+
+//lockPollInterval is the delay between 2 attempts to take a lock held by another check
+const lockPollInterval = 50 * time.Millisecond
+
+//waitLock call try until it takes the lock. It returns a LockError at the end of the lock timeout
+//and the error of the context if it's done before, the check has no time left to wait for the lock.
+func waitLock(ctx context.Context, device string, name string, timeout time.Duration, try func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		taken, err := try()
+		if err != nil || taken {
+			return err
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return &LockError{Device: device, Name: name, Timeout: timeout}
+		}
+		if wait > lockPollInterval {
+			wait = lockPollInterval
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+//StoreOptions contains the parameters of the state storage
+type StoreOptions struct {
+	//Backend is the type of storage (file|bolt|redis), file by default
+	Backend string
+	//Directory hosts the state files of the file backend and the database of the bolt backend, CheckPath by default
+	Directory string
+	//Address of the Redis server (host:port), 127.0.0.1:6379 by default
+	Address  string
+	Password string
+	DB       int
+	Timeout  time.Duration
+	//LockTimeout is the maximum wait of the lock of an entry, DefaultLockTimeout by default
+	LockTimeout time.Duration
+}
+
+//OpenStore open the state storage given by the options
+func OpenStore(opts *StoreOptions) (StateStore, error) {
+	directory := opts.Directory
+	if directory == "" {
+		directory = CheckPath
+	}
+	lockTimeout := opts.LockTimeout
+	if lockTimeout <= 0 {
+		lockTimeout = DefaultLockTimeout
+	}
+	switch opts.Backend {
+	case "", BackendFile:
+		return NewFSStore(directory, lockTimeout)
+	case BackendBolt:
+		return NewBoltStore(directory, lockTimeout)
+	case BackendRedis:
+		address := opts.Address
+		if address == "" {
+			address = "127.0.0.1:6379"
+		}
+		return NewRedisStore(address, opts.Password, opts.DB, opts.Timeout, lockTimeout)
+	default:
+		return nil, fmt.Errorf("Unknown state backend %v, must be file, bolt or redis", opts.Backend)
+	}
+}
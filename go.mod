@@ -3,16 +3,16 @@ module go-check-network-interface
 go 1.12
 
 require (
-	github.com/golang/mock v1.4.3 // indirect
-	github.com/mitchellh/mapstructure v1.3.2
+	github.com/golang/mock v1.4.4 // indirect
+	github.com/gosnmp/gosnmp v1.32.0
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/pandaoc-io/go-shinken-check v0.3.1
 	github.com/sirupsen/logrus v1.6.0
-	github.com/soniah/gosnmp v1.25.0
 	github.com/spf13/cobra v1.0.0
 	github.com/stretchr/objx v0.2.0 // indirect
-	github.com/stretchr/testify v1.6.1 // indirect
+	github.com/stretchr/testify v1.7.0 // indirect
 	github.com/yuin/goldmark v1.1.32 // indirect
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/mod v0.3.0 // indirect
 	golang.org/x/net v0.0.0-20200625001655-4c5254603344 // indirect
 	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208 // indirect
@@ -20,6 +20,6 @@ require (
 	golang.org/x/text v0.3.3 // indirect
 	golang.org/x/tools v0.0.0-20200626171337-aa94e735be7f // indirect
 	gopkg.in/check.v1 v1.0.0-20200227125254-8fa46927fb4f // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 	rsc.io/sampler v1.99.99 // indirect
 )
@@ -0,0 +1,673 @@
+package ifcheck
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"go-check-network-interface/file"
+	"go-check-network-interface/netint"
+	"go-check-network-interface/profile"
+	"go-check-network-interface/snmp"
+	"go-check-network-interface/ui"
+
+	g "github.com/gosnmp/gosnmp"
+	sknchk "github.com/pandaoc-io/go-shinken-check"
+	log "github.com/sirupsen/logrus"
+)
+
+//Options contains all the parameters of the check of the interfaces
+type Options struct {
+	SNMP snmp.Options
+	//Interface is the pattern used to select the interface checked by Check
+	Interface string
+	//MatchBy is the attribute on which the interface is selected (name|descr|alias|index|mac), name by default
+	MatchBy string
+	//MatchType is the type of matching used to select the interface (exact|prefix|regex), exact by default
+	MatchType string
+	//Interfaces is the list of patterns used to select the interfaces checked by CheckAll, "all" select all the interfaces
+	Interfaces []string
+	//InterfacesRegex is a regular expression on the MatchBy attribute used to select the interfaces checked by CheckAll
+	InterfacesRegex string
+	//IndexExpiration is the maximum age of the interfaces index file
+	IndexExpiration time.Duration
+	//Settings are the thresholds, speed override, checks and status policy given on the command line, they override the profile
+	Settings profile.Settings
+	//Defaults are the values of the settings used when neither the command line nor the profile set them
+	Defaults profile.Settings
+	//Profiles are applied to the interfaces matching them, no profile is applied if nil
+	Profiles *profile.Config
+	//State is the storage of the index and of the previous polling of the interfaces
+	State file.StoreOptions
+	//History defines the samples kept in the state and the value tested against the thresholds
+	History netint.HistoryOptions
+}
+
+//Stats contains the statistics computed for one direction of the interface, nil when not available
+type Stats struct {
+	//Rate is expressed in bits/sec for the bandwidth and in pps for the errors and discards
+	Rate    *float64
+	Percent *float64
+}
+
+//Result is the outcome of the check of an interface
+type Result struct {
+	Name        string
+	Status      sknchk.Status
+	AdminStatus string
+	OperStatus  string
+	//Summary is a short description of the state of the interface
+	Summary      string
+	FirstPolling bool
+	//ExpectedDown is true when the interface is expected to be down (expect-down setting)
+	ExpectedDown bool
+	//DeviceChanged is true when the previous polling was done on another device, the statistics have been reinitialised
+	DeviceChanged bool
+	//Evaluated is false when the statistics haven't been computed (interface down or first polling)
+	Evaluated bool
+	//Speed of the interface in bps
+	Speed        uint
+	Duplex       string
+	InBandwidth  Stats
+	OutBandwidth Stats
+	InErrors     Stats
+	OutErrors    Stats
+	InDiscards   Stats
+	OutDiscards  Stats
+	//Profile is the name of the profile applied to the interface, empty if none
+	Profile string
+	//Thresholds are the thresholds applied to the interface
+	Thresholds *ui.Thresholds
+	//Interface contains all the raw and computed values of the interface
+	Interface *netint.InterfaceDetails
+	//Check contains the short output and the perfdata to display
+	Check *netint.Check
+	//Evaluation contains the results of the tests of the metrics against the thresholds, nil if not evaluated
+	Evaluation *netint.Evaluation
+}
+
+//Check poll the interface selected by the options, compute its statistics from the previous polling
+//and test them against the thresholds
+func Check(ctx context.Context, opts *Options) (*Result, error) {
+	err := opts.History.Validate()
+	if err != nil {
+		return nil, err
+	}
+	err = validateSettings(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	snmpConnection, err := snmp.CreateConnection(ctx, &opts.SNMP)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseConnection, fmt.Errorf("Error while Creating SNMP connection : %w", err))
+	}
+	defer snmpConnection.Conn.Close()
+
+	identity, err := netint.GetDeviceIdentity(snmpConnection)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseConnection, err)
+	}
+
+	matcher, err := netint.NewMatcher(opts.Interface, matchBy(opts), matchType(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	//The index is kept with the states
+	state, err := openState(opts, identity)
+	if err != nil {
+		return nil, err
+	}
+	defer state.Close()
+
+	intFilename := strings.ReplaceAll(opts.Interface, "/", "_") + ".json"
+	if matcher.By != netint.MatchByName || matcher.Type != netint.MatchExact {
+		//Avoid the collision with the state file of an interface named like the pattern
+		intFilename = matcher.By + "_" + matcher.Type + "_" + intFilename
+	}
+
+	//Check and prepare the index file
+	asExp := false
+	err = state.exist("index.json")
+	if err == nil {
+		asExp, err = file.AsExp(state.store, state.device, "index.json", opts.IndexExpiration)
+		if err != nil {
+			return nil, phaseError(ctx, PhaseIndex, fmt.Errorf("Error while accessing Index file : %w", err))
+		}
+		if asExp {
+			log.Debugln("Regeneration of the file...")
+		}
+	}
+	if err != nil || asExp {
+		err = createIndexFile(snmpConnection, state)
+		if err != nil {
+			return nil, phaseError(ctx, PhaseIndex, err)
+		}
+	}
+
+	var index string
+	index, err = file.FindIntIndex(state.store, state.device, identity, matcher)
+	var ambiguous *file.AmbiguousIndexError
+	if errors.As(err, &ambiguous) {
+		return nil, err
+	}
+	if err != nil {
+		log.Debugln("No interface found, force the recreation of the index file...")
+		err = createIndexFile(snmpConnection, state)
+		if err != nil {
+			return nil, phaseError(ctx, PhaseIndex, err)
+		}
+		index, err = file.FindIntIndex(state.store, state.device, identity, matcher)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//Retrieve interface information
+	intNewData, err := netint.FetchAllDatas(snmpConnection, index)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseFetch, err, intNewData)
+	}
+	err = intNewData.GetUpTime(snmpConnection)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseFetch, err, intNewData)
+	}
+	err = fetchExtras(snmpConnection, identity, []*netint.InterfaceDetails{intNewData}, opts)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseFetch, err, intNewData)
+	}
+
+	chk := netint.NewCheck(&sknchk.Check{}, "")
+	result, err := checkInterface(ctx, intNewData, chk, state, intFilename, opts)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case result.AdminStatus == "DOWN":
+		chk.AddShort(adminDownMessage(result), false)
+	case result.DeviceChanged:
+		chk.AddShort("The device has been replaced since the last polling, creation of the initial datas.", false)
+	case result.FirstPolling:
+		chk.AddShort("First polling, creation of the initial datas.", false)
+	case result.Evaluated:
+		switch chk.Rc() {
+		case sknchk.RcOk:
+			chk.PrependShort("No error found on the interface.", false)
+		case sknchk.RcWarning:
+			chk.PrependShort("Error(s) found on the interface:", false)
+		case sknchk.RcCritical:
+			chk.PrependShort("Critical Error(s) found on the interface:", false)
+		}
+	}
+	return result, nil
+}
+
+//Phases of the check, used to report which one was running when the deadline has been reached
+const (
+	PhaseConnection = "connection"
+	PhaseIndex      = "index walk"
+	PhaseFetch      = "fetch"
+	PhaseState      = "state file"
+)
+
+//DeadlineError is returned when the deadline of the check's context has been reached
+type DeadlineError struct {
+	Phase string
+	Err   error
+	//Interfaces contains the datas gathered before the deadline
+	Interfaces []*netint.InterfaceDetails
+}
+
+func (e *DeadlineError) Error() string {
+	return fmt.Sprintf("Check deadline reached during the %v phase : %v", e.Phase, e.Err)
+}
+
+func (e *DeadlineError) Unwrap() error {
+	return e.Err
+}
+
+//phaseError return a DeadlineError if the context is done, the error unchanged otherwise
+func phaseError(ctx context.Context, phase string, err error, partial ...*netint.InterfaceDetails) error {
+	if ctx.Err() == nil {
+		return err
+	}
+	var interfaces []*netint.InterfaceDetails
+	for _, networkinterface := range partial {
+		if networkinterface != nil {
+			interfaces = append(interfaces, networkinterface)
+		}
+	}
+	return &DeadlineError{Phase: phase, Err: ctx.Err(), Interfaces: interfaces}
+}
+
+//createIndexFile walk the interfaces tables and store the index of each interface into the index file
+func createIndexFile(snmpConnection *g.GoSNMP, state *deviceState) error {
+	err := netint.CreateIndexMap(snmpConnection)
+	if err != nil {
+		return fmt.Errorf("Error while Creating IndexMap : %w", err)
+	}
+	return state.save("index.json", netint.IndexList)
+}
+
+//checkInterface check the status of the interface, compute its statistics from the state file and update it.
+func checkInterface(ctx context.Context, intNewData *netint.InterfaceDetails, chk *netint.Check, state *deviceState, intFilename string, opts *Options) (*Result, error) {
+	settings, p, err := interfaceSettings(intNewData, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Name: interfaceName(intNewData), Thresholds: settings.thresholds, Interface: intNewData, Check: chk}
+	if p != nil {
+		log.Debugf("Profile %v applied to the interface", p.Name)
+		result.Profile = p.Name
+	}
+	if intNewData.IfAdminStatus != nil {
+		result.AdminStatus = netint.OperToString(*intNewData.IfAdminStatus)
+	}
+	if intNewData.IfOperStatus != nil {
+		result.OperStatus = netint.OperToString(*intNewData.IfOperStatus)
+	}
+
+	//Check if interface is admin down, in this case no need to process other information.
+	if intNewData.IfAdminStatus != nil && *intNewData.IfAdminStatus == netint.DOWN {
+		//Ok status by default, because the action have been made consciously
+		chk.AddStatus(settings.status.AdminDownState())
+		result.Status = chk.Rc()
+		result.ExpectedDown = settings.status.ExpectDown
+		result.Summary = "administratively DOWN"
+		if result.ExpectedDown {
+			result.Summary += " as expected"
+		}
+		//In the single interface mode the message is added by Check
+		if result.Status != sknchk.RcOk && chk.Prefix != "" {
+			chk.AddShort(adminDownMessage(result), true)
+		}
+		return result, nil
+	}
+	if intNewData.IfOperStatus != nil && (*intNewData.IfOperStatus != netint.UP || settings.status.ExpectDown) {
+		rc := settings.status.OperState(*intNewData.IfOperStatus)
+		message := fmt.Sprintf("The interface status is %v (oper), %v (admin)", netint.FmtState(rc, result.OperStatus), sknchk.FmtOk("UP"))
+		if since, ok := intNewData.LastChange(); ok {
+			message += fmt.Sprintf(" since %v", since.Round(time.Second))
+		}
+		result.Summary = result.OperStatus
+		//The status of a subinterface follows the one of its parent
+		if layer := intNewData.DownLayer(); layer != nil {
+			cause := fmt.Sprintf("parent %v is %v", layer.Name, netint.OperToString(*layer.OperStatus))
+			message += ", " + cause
+			result.Summary += " (" + cause + ")"
+		}
+		result.ExpectedDown = settings.status.ExpectDown
+		if result.ExpectedDown {
+			message += ", expected DOWN"
+			result.Summary += ", expected DOWN"
+		}
+		//In the multi interfaces mode the message is added to the list of the errors
+		if rc != sknchk.RcOk || chk.Prefix == "" {
+			chk.AddShort(message, chk.Prefix != "")
+		}
+		chk.AddStatus(rc)
+		result.Status = chk.Rc()
+		return result, nil
+	}
+
+	log.Debug("=====================")
+	log.Debugf("New network interface values : %#v", *intNewData)
+
+	unlock, err := state.lock(ctx, intFilename, intNewData)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	err = state.exist(intFilename)
+	if err != nil && !errors.Is(err, file.ErrStateNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		log.Debug("First polling, creation of the first json datas file")
+		err = state.save(intFilename, *intNewData)
+		if err != nil {
+			return nil, err
+		}
+		result.FirstPolling = true
+		result.Summary = "UP, first polling"
+		return result, nil
+	}
+	log.Debug("Not First polling, calculation of the elements")
+	log.Debug("Read of the old datas")
+	intOldData, err := state.read(intFilename)
+	var corrupted *file.StateCorruptedError
+	var changed *file.DeviceChangedError
+	if errors.As(err, &corrupted) || errors.As(err, &changed) {
+		//The next polling will be able to compute the statistics from the new datas
+		if writeErr := state.save(intFilename, *intNewData); writeErr != nil {
+			return nil, writeErr
+		}
+	}
+	if changed != nil {
+		//The counters of another device can't be compared with the new ones
+		log.Debugf("%v", changed)
+		result.FirstPolling = true
+		result.DeviceChanged = true
+		result.Summary = "UP, first polling (device replaced)"
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	timeDiff := computeTimeDiff(intNewData, intOldData)
+
+	eval := &netint.Evaluation{Options: &settings.history, History: intOldData.History, Timestamp: intNewData.Timestamp}
+	err = evaluate(intNewData, intOldData, timeDiff, chk, settings, eval)
+	if err != nil {
+		return nil, err
+	}
+	sample := netint.HistorySample{Timestamp: intNewData.Timestamp, Values: intNewData.Metrics()}
+	intNewData.History = intOldData.History.Append(sample, &settings.history)
+
+	log.Debug("===== Write New Data to JSON file =====")
+	err = state.save(intFilename, *intNewData)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Evaluated = true
+	result.Evaluation = eval
+	result.Summary = "UP"
+	result.Status = chk.Rc()
+	if intNewData.SpeedInbit != nil {
+		result.Speed = *intNewData.SpeedInbit
+	}
+	if intNewData.Dot3StatsDuplexStatus != nil {
+		result.Duplex = netint.DuplexToString(*intNewData.Dot3StatsDuplexStatus)
+	}
+	result.InBandwidth = Stats{Rate: intNewData.IfInRate, Percent: intNewData.IfInPrct}
+	result.OutBandwidth = Stats{Rate: intNewData.IfOutRate, Percent: intNewData.IfOutPrct}
+	result.InErrors = Stats{Rate: intNewData.IfInErrorsRate, Percent: intNewData.IfInErrorsPrct}
+	result.OutErrors = Stats{Rate: intNewData.IfOutErrorsRate, Percent: intNewData.IfOutErrorsPrct}
+	result.InDiscards = Stats{Rate: intNewData.IfInDiscardsRate, Percent: intNewData.IfInDiscardsPrct}
+	result.OutDiscards = Stats{Rate: intNewData.IfOutDiscardsRate, Percent: intNewData.IfOutDiscardsPrct}
+	return result, nil
+}
+
+//computeTimeDiff return the time elapsed between the 2 pollings.
+//If the device has rebooted in the meantime, the old counters are reset and the time diff is the uptime.
+func computeTimeDiff(intNewData *netint.InterfaceDetails, intOldData *netint.InterfaceDetails) time.Duration {
+	var sysUpTime time.Duration
+	if intNewData.UpTime != nil {
+		sysUpTime = time.Duration(int64(*intNewData.UpTime/100)) * time.Second
+		log.Debugf("Uptime : %v", sysUpTime)
+	} else {
+		log.Debugf("No Uptime found")
+	}
+
+	timeDiff := time.Unix(intNewData.Timestamp, 0).Sub(time.Unix(intOldData.Timestamp, 0))
+	log.Debugf("Time diff between the 2 polling : %v", timeDiff.String())
+	if intNewData.UpTime != nil && timeDiff > sysUpTime {
+		log.Debugf("timediff is upper than the uptime : (%v > %v)", timeDiff.String(), sysUpTime.String())
+		//If it's because of a overflow on the uptime counter (device up more than 497 days) we don't reset the old values.
+		//we admit that if the old sysUptime is near from the value 2^32-1 (counter32) so the counter simply reset because of an overflow and not a reboot.
+		//1 day = 86400 sec
+		if intOldData.UpTime == nil {
+			log.Debug("Old Uptime value isn't available, skip check of the counter overflow...")
+		} else {
+			if *intOldData.UpTime > (math.MaxUint32 - 86400) {
+				log.Debug("sysUptime counter overflow")
+				log.Debug("Don't force the old counter values to 0")
+			} else {
+				//As the system have rebooted the counter have normally reset. We force the old values to O.
+				//We force the time diff to the uptime value
+				timeDiff = sysUpTime
+				elementList := []string{
+					"IfInOctets",
+					"IfInUcastPkts",
+					"IfInNUcastPkts",
+					"IfInDiscards",
+					"IfInErrors",
+					"IfOutOctets",
+					"IfOutUcastPkts",
+					"IfOutNUcastPkts",
+					"IfOutDiscards",
+					"IfOutErrors",
+					"IfHCInOctets",
+					"IfHCInUcastPkts",
+					"IfHCInMulticastPkts",
+					"IfHCInBroadcastPkts",
+					"IfHCOutOctets",
+					"IfHCOutUcastPkts",
+					"IfHCOutMulticastPkts",
+					"IfHCOutBroadcastPkts",
+				}
+				elementList = append(elementList, netint.Dot3Counters()...)
+				for _, elem := range elementList {
+					if !reflect.ValueOf(intOldData).Elem().FieldByName(elem).IsNil() {
+						zeroValue := uint(0)
+						reflect.ValueOf(intOldData).Elem().FieldByName(elem).Set(reflect.ValueOf(&zeroValue))
+					}
+				}
+			}
+		}
+	}
+	return timeDiff
+}
+
+//evaluate compute all the statistics of the interface and test them against the thresholds
+func evaluate(intNewData *netint.InterfaceDetails, intOldData *netint.InterfaceDetails, timeDiff time.Duration, chk *netint.Check, settings *settings, eval *netint.Evaluation) error {
+	thresholds := *settings.thresholds
+	//speed is also used for the creation of the bandwidtch perfdata. Need to be called before Bandwidth function
+	netint.Speed(intNewData, chk, settings.speedOverride)
+
+	err := netint.Bandwidth(intNewData, intOldData, timeDiff, chk, thresholds.In.Bandwidth, thresholds.Out.Bandwidth, eval)
+	if err != nil {
+		return err
+	}
+
+	netint.Flaps(intNewData, intOldData, chk, thresholds.Flaps, eval)
+	//The errors and discards raised by the link negotiation mustn't be alerted right after the interface came up
+	if since, ok := intNewData.LastChange(); ok && since < settings.gracePeriod {
+		log.Debugf("Last status change %v ago, in the grace period of %v", since, settings.gracePeriod)
+		chk.AddShort(fmt.Sprintf("The interface came up %v ago, the errors and discards aren't alerted during %v",
+			since.Round(time.Second), settings.gracePeriod), true)
+		for _, dt := range []*ui.DirectionThresholds{&thresholds.In, &thresholds.Out} {
+			dt.Errors.Disabled, dt.Discards.Disabled = true, true
+		}
+		thresholds.Dot3Errors.Disabled = true
+	}
+
+	err = netint.Packets(intNewData, intOldData, timeDiff)
+	if err != nil {
+		return err
+	}
+
+	err = netint.Errors(intNewData, intOldData, timeDiff, chk, thresholds.In.Errors, thresholds.Out.Errors, thresholds.Dot3Errors, eval)
+	if err != nil {
+		return err
+	}
+
+	err = netint.Collisions(intNewData, intOldData, timeDiff, chk, thresholds.Collisions, eval)
+	if err != nil {
+		return err
+	}
+
+	err = netint.Discards(intNewData, intOldData, timeDiff, chk, thresholds.In.Discards, thresholds.Out.Discards, eval)
+	if err != nil {
+		return err
+	}
+
+	netint.DuplexMode(intNewData, chk)
+	netint.DuplexMismatch(intNewData, chk, settings.duplexMismatch)
+	netint.Transceiver(intNewData, chk, thresholds.Optics, eval)
+	return netint.Aggregation(intNewData, intOldData, timeDiff, chk, thresholds.Lag, eval)
+}
+
+//fetchExtras retrieve the layers below the interfaces, and the measures of the optical transceivers and the members
+//of the link aggregations of the interfaces on which they're checked
+func fetchExtras(snmpConnection *g.GoSNMP, identity *netint.DeviceIdentity, interfaces []*netint.InterfaceDetails, opts *Options) error {
+	if err := netint.FetchStacks(snmpConnection, interfaces); err != nil {
+		return fmt.Errorf("Error while walking the ifStackTable : %w", err)
+	}
+	var optics, lags []*netint.InterfaceDetails
+	for _, intData := range interfaces {
+		s, _, err := interfaceSettings(intData, opts)
+		if err != nil {
+			return err
+		}
+		if !s.thresholds.Optics.Disabled {
+			optics = append(optics, intData)
+		}
+		if !s.thresholds.Lag.Disabled {
+			lags = append(lags, intData)
+		}
+	}
+	if len(optics) > 0 {
+		if err := netint.FetchOptics(snmpConnection, identity, optics); err != nil {
+			return fmt.Errorf("Error while fetching the optical transceivers : %w", err)
+		}
+	}
+	if len(lags) > 0 {
+		if err := netint.FetchLags(snmpConnection, lags); err != nil {
+			return fmt.Errorf("Error while fetching the members of the aggregations : %w", err)
+		}
+	}
+	return nil
+}
+
+//settings are the thresholds, the speed override and the status policy applied to an interface
+type settings struct {
+	thresholds    *ui.Thresholds
+	speedOverride netint.SpeedOverride
+	status        *netint.StatusPolicy
+	//gracePeriod is the time after a status change during which the errors and discards alerts are suppressed
+	gracePeriod time.Duration
+	//duplexMismatch is the state of the check when a duplex mismatch is suspected
+	duplexMismatch sknchk.Status
+	//history is the history options extended to cover the longest duration of the thresholds
+	history netint.HistoryOptions
+}
+
+//interfaceSettings return the settings of the interface and the profile applied to it, if any.
+//The settings of the command line override the ones of the profile, which override the defaults.
+func interfaceSettings(intNewData *netint.InterfaceDetails, opts *Options) (*settings, *profile.Profile, error) {
+	p := opts.Profiles.Find(intNewData)
+	s, err := resolveSettings(p, opts)
+	return s, p, err
+}
+
+//resolveSettings read the settings resulting of the profile, nil for none, and of the options
+func resolveSettings(p *profile.Profile, opts *Options) (*settings, error) {
+	layers := []profile.Settings{opts.Defaults}
+	if p != nil {
+		layers = append(layers, p.Settings)
+	}
+	merged := profile.Merge(append(layers, opts.Settings)...)
+	s := &settings{}
+	var err error
+	s.thresholds, err = ui.NewSettingsThresholds(merged)
+	if err != nil {
+		return nil, err
+	}
+	s.speedOverride, err = ui.NewSpeedOverride(merged["speed-override"])
+	if err != nil {
+		return nil, err
+	}
+	s.status, err = ui.NewStatusPolicy(merged)
+	if err != nil {
+		return nil, err
+	}
+	s.history = opts.History
+	s.history.Retention = s.thresholds.LongestDuration()
+	if s.history.Window > 0 && s.history.Window < s.history.Retention {
+		return nil, fmt.Errorf("The history window %v is shorter than the %v duration of a threshold, the alert could never be raised. See usage for more details.",
+			s.history.Window, s.history.Retention)
+	}
+	if merged["duplex-mismatch"] != "" {
+		s.duplexMismatch, err = netint.ParseState(merged["duplex-mismatch"])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid duplex-mismatch state : %v. See usage for more details.", err)
+		}
+	}
+	if merged["grace-period"] != "" {
+		s.gracePeriod, err = time.ParseDuration(merged["grace-period"])
+		if err != nil || s.gracePeriod < 0 {
+			return nil, fmt.Errorf("Invalid grace period %v, must be a positive duration like 10m. See usage for more details.", merged["grace-period"])
+		}
+	}
+	return s, nil
+}
+
+//validateSettings check the settings of the command line alone and combined with each profile,
+//to report an invalid setting before any snmp request
+func validateSettings(opts *Options) error {
+	_, err := resolveSettings(nil, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Profiles == nil {
+		return nil
+	}
+	for _, p := range opts.Profiles.Profiles {
+		_, err = resolveSettings(p, opts)
+		if err != nil {
+			return fmt.Errorf("Profile %v : %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+//adminDownMessage return the message of an interface administratively down, colored with its status
+func adminDownMessage(result *Result) string {
+	message := fmt.Sprintf("The interface is administratively %v", netint.FmtState(result.Status, "DOWN"))
+	if result.ExpectedDown {
+		message += " as expected"
+	}
+	return message
+}
+
+//interfaceName return the name used to identify the interface in the output and the state file
+func interfaceName(intNewData *netint.InterfaceDetails) string {
+	if intNewData.IfName != nil && len(*intNewData.IfName) > 0 {
+		return *intNewData.IfName
+	}
+	if intNewData.IfDescr != nil && len(*intNewData.IfDescr) > 0 {
+		return *intNewData.IfDescr
+	}
+	return fmt.Sprintf("index_%v", *intNewData.Index)
+}
+
+func matchBy(opts *Options) string {
+	if opts.MatchBy == "" {
+		return netint.MatchByName
+	}
+	return opts.MatchBy
+}
+
+func matchType(opts *Options) string {
+	if opts.MatchType == "" {
+		return netint.MatchExact
+	}
+	return opts.MatchType
+}
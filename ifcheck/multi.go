@@ -0,0 +1,230 @@
+package ifcheck
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go-check-network-interface/file"
+	"go-check-network-interface/netint"
+	"go-check-network-interface/snmp"
+	"go-check-network-interface/ui"
+
+	sknchk "github.com/pandaoc-io/go-shinken-check"
+	log "github.com/sirupsen/logrus"
+)
+
+//MultiResult is the outcome of the check of several interfaces in a single run
+type MultiResult struct {
+	Status     sknchk.Status
+	Interfaces []*Result
+	//Missing contains the patterns of the selection which haven't matched any interface
+	Missing []string
+	//Check contains the aggregated output and perfdata of all the interfaces
+	Check *sknchk.Check
+}
+
+//CheckAll check all the interfaces selected with the Interfaces or InterfacesRegex options in a single run.
+//The interfaces tables are walked once, each interface is checked like by Check and the results are aggregated.
+func CheckAll(ctx context.Context, opts *Options) (*MultiResult, error) {
+	err := opts.History.Validate()
+	if err != nil {
+		return nil, err
+	}
+	err = validateSettings(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	snmpConnection, err := snmp.CreateConnection(ctx, &opts.SNMP)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseConnection, fmt.Errorf("Error while Creating SNMP connection : %w", err))
+	}
+	defer snmpConnection.Conn.Close()
+
+	identity, err := netint.GetDeviceIdentity(snmpConnection)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseConnection, err)
+	}
+
+	interfaces, err := netint.FetchAllInterfaces(snmpConnection)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseFetch, fmt.Errorf("Error while walking the interfaces tables : %w", err))
+	}
+	device := new(netint.InterfaceDetails)
+	err = device.GetUpTime(snmpConnection)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseFetch, err)
+	}
+
+	selected, missing, err := selectInterfaces(interfaces, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("No interface matching the selection found on the device")
+	}
+	err = fetchExtras(snmpConnection, identity, selected, opts)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseFetch, err)
+	}
+
+	state, err := openState(opts, identity)
+	if err != nil {
+		return nil, err
+	}
+	defer state.Close()
+
+	chk := &sknchk.Check{}
+	result := &MultiResult{Missing: missing, Check: chk}
+	for _, name := range missing {
+		chk.AddShort(fmt.Sprintf("%v : interface not found on the device", name), true)
+		chk.AddCritical()
+	}
+	var nbOk, nbWarning, nbCritical, nbUnknown int
+	for _, intNewData := range selected {
+		name := interfaceName(intNewData)
+		intChk := netint.NewCheck(chk, name)
+		intNewData.UpTime, intNewData.SysUpTime = device.UpTime, device.SysUpTime
+
+		log.Debugf("===== Interface %v =====", name)
+		intFilename := strings.ReplaceAll(name, "/", "_") + ".json"
+		intResult, err := checkInterface(ctx, intNewData, intChk, state, intFilename, opts)
+		var corrupted *file.StateCorruptedError
+		var locked *file.LockError
+		var deadline *DeadlineError
+		if errors.As(err, &corrupted) {
+			//Only this interface is impacted, the datas have been reinitialised for the next polling
+			intChk.AddShort(fmt.Sprintf("%v, the datas have been reinitialised", corrupted), true)
+			intChk.AddUnknown()
+			intResult = &Result{Name: name, Status: intChk.Rc(), Summary: "UP, state reinitialised", Interface: intNewData, Check: intChk}
+		} else if errors.As(err, &locked) {
+			//Another check is updating the state of this interface, the other ones can still be checked
+			intChk.AddShort(locked.Error(), true)
+			intChk.AddUnknown()
+			intResult = &Result{Name: name, Status: intChk.Rc(), Summary: "UP, state locked", Interface: intNewData, Check: intChk}
+		} else if errors.As(err, &deadline) {
+			//Keep the interfaces already checked as partial datas
+			deadline.Interfaces = nil
+			for _, done := range result.Interfaces {
+				deadline.Interfaces = append(deadline.Interfaces, done.Interface)
+			}
+			return nil, deadline
+		} else if err != nil {
+			return nil, err
+		}
+		result.Interfaces = append(result.Interfaces, intResult)
+
+		switch intChk.Rc() {
+		case sknchk.RcOk:
+			nbOk++
+		case sknchk.RcWarning:
+			nbWarning++
+		case sknchk.RcCritical:
+			nbCritical++
+		default:
+			nbUnknown++
+		}
+		chk.AddLong(fmt.Sprintf("[%v] %v", rcToString(intChk.Rc()), ui.InterfaceSummary(intNewData, intResult.Summary)), true)
+	}
+
+	switch chk.Rc() {
+	case sknchk.RcOk:
+		chk.PrependShort(fmt.Sprintf("%v interfaces checked, no error found.", len(selected)), false)
+	default:
+		chk.PrependShort(fmt.Sprintf("%v interfaces checked (%v OK, %v Warning, %v Critical, %v Unknown), error(s) found:",
+			len(selected), nbOk, nbWarning, nbCritical, nbUnknown), false)
+	}
+	result.Status = chk.Rc()
+	return result, nil
+}
+
+//selectInterfaces return the interfaces matching the list or the regex given in argument, sorted by index,
+//and the patterns of the list which haven't matched any interface
+func selectInterfaces(interfaces map[int]*netint.InterfaceDetails, opts *Options) ([]*netint.InterfaceDetails, []string, error) {
+
+	all := false
+	var matchers []*netint.Matcher
+	for _, pattern := range opts.Interfaces {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "all" {
+			all = true
+		} else if pattern != "" {
+			matcher, err := netint.NewMatcher(pattern, matchBy(opts), matchType(opts))
+			if err != nil {
+				return nil, nil, err
+			}
+			matchers = append(matchers, matcher)
+		}
+	}
+	if opts.InterfacesRegex != "" {
+		matcher, err := netint.NewMatcher(opts.InterfacesRegex, matchBy(opts), netint.MatchRegex)
+		if err != nil {
+			return nil, nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	var indexes []int
+	found := make(map[*netint.Matcher]bool)
+	for index, networkinterface := range interfaces {
+		selected := all
+		attributes := networkinterface.Attributes()
+		for _, matcher := range matchers {
+			if matcher.Match(index, attributes) {
+				found[matcher] = true
+				selected = true
+			}
+		}
+		if selected {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+
+	var selected []*netint.InterfaceDetails
+	for _, index := range indexes {
+		selected = append(selected, interfaces[index])
+	}
+	var missing []string
+	for _, matcher := range matchers {
+		if !found[matcher] {
+			missing = append(missing, matcher.Pattern)
+		}
+	}
+	return selected, missing, nil
+}
+
+//rcToString convert the status to its string representation
+func rcToString(rc sknchk.Status) string {
+	switch rc {
+	case sknchk.RcOk:
+		return "OK"
+	case sknchk.RcWarning:
+		return "WARNING"
+	case sknchk.RcCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,77 @@
+package ifcheck
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+
+	"go-check-network-interface/file"
+	"go-check-network-interface/netint"
+)
+
+//deviceState is the access of a check to the states of the polled device
+type deviceState struct {
+	store  file.StateStore
+	device string
+	//identity is stored with the states to detect a device replaced behind the same address
+	identity *netint.DeviceIdentity
+}
+
+//openState open the state storage once the device has answered,
+//the storage isn't held by a check waiting for an unreachable device
+func openState(opts *Options, identity *netint.DeviceIdentity) (*deviceState, error) {
+	store, err := file.OpenStore(&opts.State)
+	if err != nil {
+		return nil, err
+	}
+	return &deviceState{store: store, device: file.GenDeviceDirName(&opts.SNMP), identity: identity}, nil
+}
+
+//lock take the lock of the state of the interface until its new datas are written, an overlapping check
+//of the same interface would compute its statistics from the same old datas.
+//The state mustn't be updated once the deadline is reached, the result wouldn't be displayed.
+func (s *deviceState) lock(ctx context.Context, filename string, intNewData *netint.InterfaceDetails) (func() error, error) {
+	if ctx.Err() != nil {
+		return nil, phaseError(ctx, PhaseState, ctx.Err(), intNewData)
+	}
+	unlock, err := s.store.Lock(ctx, s.device, filename)
+	if err != nil {
+		return nil, phaseError(ctx, PhaseState, err, intNewData)
+	}
+	return unlock, nil
+}
+
+//exist return file.ErrStateNotFound if the state hasn't been saved yet
+func (s *deviceState) exist(filename string) error {
+	return file.CheckFileExist(s.store, s.device, filename)
+}
+
+//read return the datas of the interface saved by the previous polling
+func (s *deviceState) read(filename string) (*netint.InterfaceDetails, error) {
+	return file.ReadJSONIntFile(s.store, s.device, filename, s.identity)
+}
+
+//save write the datas with the identity of the device
+func (s *deviceState) save(filename string, datas interface{}) error {
+	return file.CreateJSONFile(s.store, s.device, filename, s.identity, datas)
+}
+
+func (s *deviceState) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,94 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	sknchk "github.com/pandaoc-io/go-shinken-check"
+)
+
+//Check wraps the Shinken check used by the handlers.
+//When a Prefix is set (several interfaces checked in a single run), the short output and the perfdata labels
+//are prefixed with it, and the status raised for this interface only is kept to build the summary.
+type Check struct {
+	*sknchk.Check
+	Prefix string
+	rc     sknchk.Status
+}
+
+//NewCheck create a new Check on top of the Shinken check given in argument
+func NewCheck(chk *sknchk.Check, prefix string) *Check {
+	return &Check{Check: chk, Prefix: prefix}
+}
+
+//AddShort add a new string to the short output, prefixed by the interface name if needed
+func (c *Check) AddShort(short string, bullet bool) {
+	if c.Prefix != "" {
+		short = c.Prefix + " : " + short
+	}
+	c.Check.AddShort(short, bullet)
+}
+
+//AddPerfData add a new perfdata to the check, the label is prefixed by the interface name if needed
+func (c *Check) AddPerfData(name string, value interface{}, unit string, warn interface{}, crit interface{}, min interface{}, max interface{}) {
+	if c.Prefix != "" {
+		name = "'" + c.Prefix + "_" + name + "'"
+	}
+	c.Check.AddPerfData(name, value, unit, warn, crit, min, max)
+}
+
+//AddWarning add a Warning status to the check
+func (c *Check) AddWarning() {
+	c.raise(sknchk.RcWarning)
+	c.Check.AddWarning()
+}
+
+//AddCritical add a Critical status to the check
+func (c *Check) AddCritical() {
+	c.raise(sknchk.RcCritical)
+	c.Check.AddCritical()
+}
+
+//AddUnknown add an Unknown status to the check
+func (c *Check) AddUnknown() {
+	c.raise(sknchk.RcUnknwon)
+	c.Check.AddUnknown()
+}
+
+//Rc return the worst status raised through this Check
+func (c *Check) Rc() sknchk.Status {
+	return c.rc
+}
+
+func (c *Check) raise(rc sknchk.Status) {
+	if rc > c.rc {
+		c.rc = rc
+	}
+}
+
+//AddStatus add the status given in argument to the check, nothing is added for an Ok status
+func (c *Check) AddStatus(rc sknchk.Status) {
+	switch rc {
+	case sknchk.RcWarning:
+		c.AddWarning()
+	case sknchk.RcCritical:
+		c.AddCritical()
+	case sknchk.RcUnknwon:
+		c.AddUnknown()
+	}
+}
@@ -0,0 +1,178 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	sknchk "github.com/pandaoc-io/go-shinken-check"
+	log "github.com/sirupsen/logrus"
+)
+
+//dot3Counter is an error counter of the EtherLike-MIB dot3StatsTable
+type dot3Counter struct {
+	//elem is the field of the counter, its rate and percentage are the fields elem+"Rate" and elem+"Prct"
+	elem string
+	//label is the perfdata label and the metric of the history
+	label string
+	name  string
+	//out is true for the transmit errors, computed against the out packets
+	out bool
+}
+
+//dot3Counters are the error counters of the EtherLike-MIB available on most of the vendors and on net-snmp
+var dot3Counters = []dot3Counter{
+	{"Dot3StatsFCSErrors", "dot3_fcs_errors", "FCS Errors", false},
+	{"Dot3StatsAlignmentErrors", "dot3_alignment_errors", "Alignment Errors", false},
+	{"Dot3StatsFrameTooLongs", "dot3_frame_too_longs", "Frame Too Longs", false},
+	{"Dot3StatsInternalMacReceiveErrors", "dot3_internal_mac_rx_errors", "Internal MAC Rx Errors", false},
+	{"Dot3StatsSymbolErrors", "dot3_symbol_errors", "Symbol Errors", false},
+	{"Dot3StatsInternalMacTransmitErrors", "dot3_internal_mac_tx_errors", "Internal MAC Tx Errors", true},
+	{"Dot3StatsCarrierSenseErrors", "dot3_carrier_sense_errors", "Carrier Sense Errors", true},
+}
+
+//dot3Collisions are the collision counters of the EtherLike-MIB, only a half-duplex interface should have collisions
+var dot3Collisions = []dot3Counter{
+	{"Dot3StatsSingleCollisionFrames", "dot3_single_collisions", "Single Collisions", true},
+	{"Dot3StatsMultipleCollisionFrames", "dot3_multiple_collisions", "Multiple Collisions", true},
+	{"Dot3StatsLateCollisions", "dot3_late_collisions", "Late Collisions", true},
+	{"Dot3StatsExcessiveCollisions", "dot3_excessive_collisions", "Excessive Collisions", true},
+	{"Dot3StatsDeferredTransmissions", "dot3_deferred_transmissions", "Deferred Transmissions", true},
+}
+
+//allDot3Counters return the error and the collision counters of the EtherLike-MIB
+func allDot3Counters() []dot3Counter {
+	return append(append([]dot3Counter{}, dot3Counters...), dot3Collisions...)
+}
+
+//Dot3Counters return the fields of the error and collision counters of the EtherLike-MIB
+func Dot3Counters() []string {
+	var elems []string
+	for _, counter := range allDot3Counters() {
+		elems = append(elems, counter.elem)
+	}
+	return elems
+}
+
+//CRCRate return the rate in pps of the CRC errors, from the dot3StatsFCSErrors or the Cisco locIfInCRC as fallback
+func (i *InterfaceDetails) CRCRate() *float64 {
+	if i.Dot3StatsFCSErrorsRate != nil {
+		return i.Dot3StatsFCSErrorsRate
+	}
+	return i.LocIfInCRCRate
+}
+
+//CRCPrct returns the % of received packets with a FCS error, from the EtherLike-MIB or else from the Cisco CRC counter
+func (i *InterfaceDetails) CRCPrct() *float64 {
+	if i.Dot3StatsFCSErrorsPrct != nil {
+		return i.Dot3StatsFCSErrorsPrct
+	}
+	return i.LocIfInCRCPrct
+}
+
+//Collisions returns the rate in pps and the % of packets of each collision counter of the EtherLike-MIB,
+//the related perfdata and make the test with the thresholds to update the check
+func Collisions(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration, chk *Check, limits Limits, eval *Evaluation) error {
+	log.Debug("===== Collisions =====")
+	return dot3Stats(dot3Collisions, intNewData, intOldData, timeDiff, chk, limits, eval)
+}
+
+//dot3Stats returns the rate in pps and the % of packets of each counter of the EtherLike-MIB,
+//the related perfdata and make the test with the thresholds to update the check
+func dot3Stats(counters []dot3Counter, intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration, chk *Check, limits Limits, eval *Evaluation) error {
+	newValue, oldValue := reflect.ValueOf(intNewData).Elem(), reflect.ValueOf(intOldData).Elem()
+	for _, counter := range counters {
+		log.Debugf("===== %v =====", counter.elem)
+		newCounter, oldCounter := newValue.FieldByName(counter.elem).Interface().(*uint), oldValue.FieldByName(counter.elem).Interface().(*uint)
+		if newCounter == nil {
+			log.Debugf("No %v counter available, skip...", counter.elem)
+			continue
+		}
+		total := intNewData.IfInTotalPkts
+		if counter.out {
+			total = intNewData.IfOutTotalPkts
+		}
+		if total == nil {
+			log.Debug("No total of packets available, skip...")
+			continue
+		}
+		rate, prct, err := pckStats(newCounter, oldCounter, total, timeDiff, false, intNewData.BwInconsistency)
+		if err != nil {
+			return err
+		}
+		if rate == nil {
+			continue
+		}
+		newValue.FieldByName(counter.elem + "Rate").Set(reflect.ValueOf(rate))
+		newValue.FieldByName(counter.elem + "Prct").Set(reflect.ValueOf(prct))
+
+		warn, crit := perfThresholds("pps", limits)
+		chk.AddPerfData(counter.label, strconv.FormatFloat(*rate, 'f', 2, 64), "pps", warn, crit, 0, 0)
+		packetThresholds(chk, counter.name, counter.label, rate, prct, limits, eval)
+	}
+	return nil
+}
+
+//fcsMismatchFloor is the minimal % of the received packets with a FCS error to suspect a duplex mismatch
+//on a Full-Duplex interface without collision, below it the errors are the usual noise of a line
+const fcsMismatchFloor = 0.1
+
+//DuplexMismatch suspect a duplex mismatch with the negotiation partner from the duplex mode, the late collisions
+//and the FCS errors, and add the state given in argument to the check if so.
+//The half-duplex side of a mismatch has late collisions, the full-duplex side has FCS errors but no collision.
+func DuplexMismatch(intNewData *InterfaceDetails, chk *Check, rc sknchk.Status) {
+	log.Debug("===== Duplex Mismatch =====")
+	positive := func(rate *float64) bool { return rate != nil && *rate > 0 }
+	var collisions bool
+	for _, counter := range dot3Collisions {
+		collisions = collisions || positive(reflect.ValueOf(intNewData).Elem().FieldByName(counter.elem+"Rate").Interface().(*float64))
+	}
+	lateCollisions := positive(intNewData.Dot3StatsLateCollisionsRate)
+	fcsErrors := positive(intNewData.CRCRate())
+	fcsPrct := intNewData.CRCPrct()
+
+	//1-unknown, 2-halfDuplex, 3-fullDuplex
+	duplex := uint(1)
+	if intNewData.Dot3StatsDuplexStatus != nil {
+		duplex = *intNewData.Dot3StatsDuplexStatus
+	}
+	var reason string
+	switch {
+	case duplex == 2 && lateCollisions:
+		reason = "late collisions on a Half-Duplex interface"
+	case duplex == 3 && collisions:
+		reason = "collisions on a Full-Duplex interface"
+	case lateCollisions && fcsErrors:
+		reason = "late collisions and FCS errors"
+	case duplex == 3 && !collisions && fcsPrct != nil && *fcsPrct >= fcsMismatchFloor:
+		reason = fmt.Sprintf("%.2f%% of FCS errors without collision on a Full-Duplex interface", *fcsPrct)
+	}
+	if reason == "" {
+		return
+	}
+	log.Debugf("Duplex mismatch suspected : %v", reason)
+	if rc == sknchk.RcOk {
+		return
+	}
+	chk.AddShort(fmt.Sprintf("%v suspected : %v", FmtState(rc, "Duplex mismatch"), reason), true)
+	chk.AddStatus(rc)
+}
@@ -0,0 +1,86 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//LastChange return the time elapsed since the last change of the status of the interface, false if unknown
+//(no ifLastChange, or status entered before the initialisation of the SNMP agent)
+func (i *InterfaceDetails) LastChange() (time.Duration, bool) {
+	upTime := i.lastChangeReference()
+	if i.IfLastChange == nil || upTime == nil || *i.IfLastChange == 0 || *i.IfLastChange > *upTime {
+		return 0, false
+	}
+	return time.Duration(*upTime-*i.IfLastChange) * 10 * time.Millisecond, true
+}
+
+//lastChangeReference return the uptime to which the ifLastChange refers, the sysUpTime if available
+func (i *InterfaceDetails) lastChangeReference() *uint {
+	if i.SysUpTime != nil {
+		return i.SysUpTime
+	}
+	return i.UpTime
+}
+
+//Flaps detect a change of the status of the interface since the previous polling, even if it's UP again,
+//count the pollings with a status change in the history and test this number against its limits
+func Flaps(intNewData *InterfaceDetails, intOldData *InterfaceDetails, chk *Check, limits Limits, eval *Evaluation) {
+	log.Debug("===== Flaps =====")
+	if intNewData.IfLastChange == nil || intOldData.IfLastChange == nil {
+		log.Debug("No ifLastChange available, skip the flap detection...")
+		return
+	}
+	var changes float64
+	newUpTime, oldUpTime := intNewData.lastChangeReference(), intOldData.lastChangeReference()
+	if newUpTime != nil && oldUpTime != nil && *newUpTime < *oldUpTime {
+		//The ifLastChange is reset by a reboot of the device or a wrap of the sysUpTime, it's not a status change
+		log.Debugf("Uptime restarted since the previous polling (%v -> %v), the ifLastChange isn't compared", *oldUpTime, *newUpTime)
+	} else if *intNewData.IfLastChange != *intOldData.IfLastChange {
+		log.Debugf("ifLastChange changed since the previous polling : %v -> %v", *intOldData.IfLastChange, *intNewData.IfLastChange)
+		changes = 1
+	}
+	flaps := eval.Sum("status_changes", changes)
+	intNewData.StatusChanges, intNewData.Flaps = &changes, &flaps
+
+	warn, crit := perfThresholds("", limits)
+	chk.AddPerfData("flaps", strconv.FormatFloat(flaps, 'f', -1, 64), "", warn, crit, 0, "")
+
+	limit, critical, held, ok := eval.test("flaps", &flaps, limits)
+	if !ok {
+		return
+	}
+	lastChange := "unknown"
+	if since, ok := intNewData.LastChange(); ok {
+		lastChange = since.Round(time.Second).String() + " ago"
+	}
+	label := "status changes"
+	if flaps == 1 {
+		label = "status change"
+	}
+	chk.AddShort(fmt.Sprintf("%v flapping : %v %v%v (%v), last change %v", limit.qualify(flaps, critical),
+		fmtStatus(strconv.FormatFloat(flaps, 'f', -1, 64), critical), label, eval.Period(), limit.describe(flaps, "", held), lastChange),
+		true)
+	addStatus(chk, critical)
+}
@@ -28,23 +28,23 @@ import (
 
 	"go-check-network-interface/convert"
 
+	g "github.com/gosnmp/gosnmp"
 	sknchk "github.com/pandaoc-io/go-shinken-check"
 	log "github.com/sirupsen/logrus"
-	g "github.com/soniah/gosnmp"
-	"github.com/spf13/cobra"
 )
 
 //IndexList is the variable to strore interface index information to generate the json index file per interface
 var IndexList map[int]map[string]string
 
-//BwInconsistency is used to keep the Bandwith inconsistency status and used it to reset the pckt calculation part
-var BwInconsistency bool = false
-
-//CreateIndexMap create the map of ifDescr and ifName per index found
+//CreateIndexMap create the map of ifDescr, ifName, ifAlias and ifPhysAddress per index found
 func CreateIndexMap(snmpConnection *g.GoSNMP) error {
 	IndexList = make(map[int]map[string]string)
-	for _, oidTable := range []string{InterfaceOids["IfDescr"], InterfaceOids["IfName"]} {
-		pdus, err := snmpConnection.BulkWalkAll(oidTable)
+	for _, elem := range []string{"IfDescr", "IfName", "IfAlias", "IfPhysAddress"} {
+		if snmpConnection.Version == g.Version1 && isIfXElem(elem) {
+			continue
+		}
+		oidTable := InterfaceOids[elem]
+		pdus, err := walkAll(snmpConnection, oidTable)
 		if err != nil {
 			return err
 		}
@@ -60,10 +60,10 @@ func CreateIndexMap(snmpConnection *g.GoSNMP) error {
 				if IndexList[index] == nil {
 					IndexList[index] = make(map[string]string)
 				}
-				if oidTable == InterfaceOids["IfDescr"] {
-					IndexList[index]["IfDescr"] = string(bytes)
+				if elem == "IfPhysAddress" {
+					IndexList[index][elem] = FormatMac(string(bytes))
 				} else {
-					IndexList[index]["IfName"] = string(bytes)
+					IndexList[index][elem] = string(bytes)
 				}
 			}
 		}
@@ -72,7 +72,7 @@ func CreateIndexMap(snmpConnection *g.GoSNMP) error {
 }
 
 //FetchAllDatas grab all the interface details by SNMP
-func FetchAllDatas(snmpConnection *g.GoSNMP, index string, version string, cmd *cobra.Command) (*InterfaceDetails, error) {
+func FetchAllDatas(snmpConnection *g.GoSNMP, index string) (*InterfaceDetails, error) {
 
 	networkinterface := &InterfaceDetails{}
 	networkinterface.Index = new(int)
@@ -81,9 +81,11 @@ func FetchAllDatas(snmpConnection *g.GoSNMP, index string, version string, cmd *
 	elementList := []string{
 		"IfName",
 		"IfDescr",
+		"IfType",
 		"IfSpeed",
 		"IfAdminStatus",
 		"IfOperStatus",
+		"IfLastChange",
 		"IfInOctets",
 		"IfInUcastPkts",
 		"IfInNUcastPkts",
@@ -104,26 +106,105 @@ func FetchAllDatas(snmpConnection *g.GoSNMP, index string, version string, cmd *
 		"IfHCOutBroadcastPkts",
 		"IfHighSpeed",
 		"IfAlias",
+		"IfPhysAddress",
 		"LocIfInCRC",
 		"Dot3StatsDuplexStatus",
+		"Dot3StatsAlignmentErrors",
+		"Dot3StatsFCSErrors",
+		"Dot3StatsInternalMacTransmitErrors",
+		"Dot3StatsCarrierSenseErrors",
+		"Dot3StatsFrameTooLongs",
+		"Dot3StatsInternalMacReceiveErrors",
+		"Dot3StatsSymbolErrors",
+		"Dot3StatsSingleCollisionFrames",
+		"Dot3StatsMultipleCollisionFrames",
+		"Dot3StatsDeferredTransmissions",
+		"Dot3StatsLateCollisions",
+		"Dot3StatsExcessiveCollisions",
+	}
+	if snmpConnection.Version == g.Version1 {
+		//Only the 32 bits counters of the ifTable are available in SNMP v1
+		var ifTableList []string
+		for _, elem := range elementList {
+			if !isIfXElem(elem) {
+				ifTableList = append(ifTableList, elem)
+			}
+		}
+		elementList = ifTableList
 	}
-	for _, elem := range elementList {
-		err := networkinterface.GetData(snmpConnection, elem)
+	err := networkinterface.GetDatas(snmpConnection, elementList)
+	if err != nil {
+		//The datas already received are returned with the error
+		return networkinterface, err
+	}
+	if networkinterface.IfAlias != nil {
+		log.Debug("Replace the characters of the alias '|' by '!'")
+		*networkinterface.IfAlias = strings.ReplaceAll(*networkinterface.IfAlias, "|", "!")
+	}
+	if networkinterface.IfPhysAddress != nil {
+		*networkinterface.IfPhysAddress = FormatMac(*networkinterface.IfPhysAddress)
+	}
+	networkinterface.Timestamp = (time.Now().Unix())
+
+	return networkinterface, nil
+}
+
+//FetchAllInterfaces grab the details of all the interfaces of the device.
+//Instead of polling each element per interface, the ifTable/ifXTable and the additional tables are walked once.
+func FetchAllInterfaces(snmpConnection *g.GoSNMP) (map[int]*InterfaceDetails, error) {
+	interfaces := make(map[int]*InterfaceDetails)
+	columns := make(map[string]string)
+	for elem, oid := range InterfaceOids {
+		if elem == "HrSystemUptime" || elem == "SysUpTime" {
+			continue
+		}
+		columns[oid] = elem
+	}
+
+	for _, oidTable := range []string{ifEntryBaseOid, ifXEntryBaseOid, InterfaceOids["LocIfInCRC"], dot3StatsEntryBaseOid} {
+		if snmpConnection.Version == g.Version1 && oidTable == ifXEntryBaseOid {
+			log.Debug("No ifXTable in SNMP v1, skip...")
+			continue
+		}
+		log.Debugf("Walk of the table %v", oidTable)
+		pdus, err := walkAll(snmpConnection, oidTable)
 		if err != nil {
 			return nil, err
 		}
-		if elem == "IfAlias" && networkinterface.IfAlias != nil {
-			log.Debug("Replace the characters of the alias '|' by '!'")
-			*networkinterface.IfAlias = strings.ReplaceAll(*networkinterface.IfAlias, "|", "!")
+		for _, variable := range pdus {
+			name := "." + strings.TrimPrefix(variable.Name, ".")
+			dot := strings.LastIndex(name, ".")
+			elem, ok := columns[name[:dot]]
+			if !ok {
+				continue
+			}
+			index, err := strconv.Atoi(name[dot+1:])
+			if err != nil {
+				return nil, err
+			}
+			if interfaces[index] == nil {
+				interfaces[index] = &InterfaceDetails{Index: new(int)}
+				*interfaces[index].Index = index
+			}
+			interfaces[index].setData(elem, variable)
 		}
 	}
-	networkinterface.Timestamp = (time.Now().Unix())
 
-	return networkinterface, nil
+	now := time.Now().Unix()
+	for _, networkinterface := range interfaces {
+		if networkinterface.IfAlias != nil {
+			*networkinterface.IfAlias = strings.ReplaceAll(*networkinterface.IfAlias, "|", "!")
+		}
+		if networkinterface.IfPhysAddress != nil {
+			*networkinterface.IfPhysAddress = FormatMac(*networkinterface.IfPhysAddress)
+		}
+		networkinterface.Timestamp = now
+	}
+	return interfaces, nil
 }
 
 //Diff will return the difference between 2 values. Used to make the diff between 2 counters (32 or 64 bits)
-func diff(newData interface{}, oldData interface{}, is64 bool) (uint, error) {
+func diff(newData interface{}, oldData interface{}, is64 bool, inconsistency bool) (uint, error) {
 	if reflect.TypeOf(newData) != reflect.TypeOf(oldData) {
 		return 0, fmt.Errorf("2 different value types provided : %v, %v", reflect.TypeOf(newData), reflect.TypeOf(oldData))
 	}
@@ -146,7 +227,7 @@ func diff(newData interface{}, oldData interface{}, is64 bool) (uint, error) {
 	diff := uint(0)
 	if newDataConverted == oldDataConverted {
 		diff = 0
-	} else if BwInconsistency {
+	} else if inconsistency {
 		//If Bandwidth inconsistency is found we also apply the calculation only on the new value
 		log.Debug("Inconsistency found, diff based only on the new value")
 		diff = 0
@@ -164,22 +245,23 @@ func diff(newData interface{}, oldData interface{}, is64 bool) (uint, error) {
 }
 
 //Bandwidth will return the rate in bps and the usage in % of the link, the related perfdata and make the test with the thresholds to update the check
-func Bandwidth(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration, chk *sknchk.Check, bw float64, bc float64) {
+//The thresholds are tested against the value given by the evaluation.
+func Bandwidth(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration, chk *Check, in Limits, out Limits, eval *Evaluation) error {
 	var err error
 	log.Debug("===== IfHCInOctets =====")
 	if intNewData.IfHCInOctets != nil {
-		intNewData.IfInRate, intNewData.IfInPrct, err = bwStats(intNewData.IfHCInOctets, intOldData.IfHCInOctets, *intNewData.IfHighSpeed*1000000, timeDiff, true)
+		intNewData.IfInRate, intNewData.IfInPrct, err = bwStats(intNewData.IfHCInOctets, intOldData.IfHCInOctets, *intNewData.InSpeed, timeDiff, true, &intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCInOctets counter available, skip...")
 	}
 	log.Debug("===== IfHCOutOctets =====")
 	if intNewData.IfHCOutOctets != nil {
-		intNewData.IfOutRate, intNewData.IfOutPrct, err = bwStats(intNewData.IfHCOutOctets, intOldData.IfHCOutOctets, *intNewData.IfHighSpeed*1000000, timeDiff, true)
+		intNewData.IfOutRate, intNewData.IfOutPrct, err = bwStats(intNewData.IfHCOutOctets, intOldData.IfHCOutOctets, *intNewData.OutSpeed, timeDiff, true, &intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCOutOctets counter available, skip...")
@@ -188,76 +270,128 @@ func Bandwidth(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeD
 		log.Debug("In/Out 64 bits counters not present, try 32 counters")
 		log.Debug("===== IfInOctets =====")
 		if intNewData.IfInOctets != nil {
-			intNewData.IfInRate, intNewData.IfInPrct, err = bwStats(intNewData.IfInOctets, intOldData.IfInOctets, *intNewData.IfSpeed, timeDiff, false)
+			intNewData.IfInRate, intNewData.IfInPrct, err = bwStats(intNewData.IfInOctets, intOldData.IfInOctets, *intNewData.InSpeed, timeDiff, false, &intNewData.BwInconsistency)
 			if err != nil {
-				sknchk.Unknown(fmt.Sprint(err), "")
+				return err
 			}
 		} else {
 			log.Debug("No IfInOctets counter available, skip...")
 		}
 		log.Debug("===== IfOutOctets =====")
 		if intNewData.IfOutOctets != nil {
-			intNewData.IfOutRate, intNewData.IfOutPrct, err = bwStats(intNewData.IfOutOctets, intOldData.IfOutOctets, *intNewData.IfSpeed, timeDiff, false)
+			intNewData.IfOutRate, intNewData.IfOutPrct, err = bwStats(intNewData.IfOutOctets, intOldData.IfOutOctets, *intNewData.OutSpeed, timeDiff, false, &intNewData.BwInconsistency)
 			if err != nil {
-				sknchk.Unknown(fmt.Sprint(err), "")
+				return err
 			}
 		} else {
 			log.Debug("No IfOutOctets counter available, skip...")
 		}
 	}
 
-	//Force 0% bandwidth usage for interfaces named vlanxxx
-	if (intNewData.IfName != nil && strings.Contains(strings.ToLower(*intNewData.IfName), "vlan")) || (intNewData.IfDescr != nil && strings.Contains(strings.ToLower(*intNewData.IfDescr), "vlan")) {
-		intNewData.IfInPrct = nil
-		intNewData.IfOutPrct = nil
-	}
+	warn, crit := perfThresholds("bps", in)
 	if intNewData.IfInRate != nil {
 		//We suppress the UOM to be compatible with the Nagvis weathermap feature (value expressed in bps)
-		chk.AddPerfData("in", strconv.FormatFloat(*intNewData.IfInRate, 'f', 2, 64), "", 0, 0, 0, *intNewData.SpeedInbit)
+		chk.AddPerfData("in", strconv.FormatFloat(*intNewData.IfInRate, 'f', 2, 64), "", warn, crit, 0, *intNewData.InSpeed)
 	}
+	warn, crit = perfThresholds("%", in)
 	if intNewData.IfInPrct != nil {
-		chk.AddPerfData("in_usage", strconv.FormatFloat(*intNewData.IfInPrct, 'f', 2, 64), "%", 0, 0, 0, 100)
+		chk.AddPerfData("in_usage", strconv.FormatFloat(*intNewData.IfInPrct, 'f', 2, 64), "%", warn, crit, 0, 100)
 	}
 
+	warn, crit = perfThresholds("bps", out)
 	if intNewData.IfOutRate != nil {
 		//We suppress the UOM to be compatible with the Nagvis weathermap feature (value expressed in bps)
-		chk.AddPerfData("out", strconv.FormatFloat(*intNewData.IfOutRate, 'f', 2, 64), "", 0, 0, 0, *intNewData.SpeedInbit)
+		chk.AddPerfData("out", strconv.FormatFloat(*intNewData.IfOutRate, 'f', 2, 64), "", warn, crit, 0, *intNewData.OutSpeed)
 	}
+	warn, crit = perfThresholds("%", out)
 	if intNewData.IfOutPrct != nil {
-		chk.AddPerfData("out_usage", strconv.FormatFloat(*intNewData.IfOutPrct, 'f', 2, 64), "%", 0, 0, 0, 100)
+		chk.AddPerfData("out_usage", strconv.FormatFloat(*intNewData.IfOutPrct, 'f', 2, 64), "%", warn, crit, 0, 100)
 	}
 
-	if intNewData.IfInPrct != nil && *intNewData.IfInPrct > bc {
-		chk.AddShort(fmt.Sprintf(`Very high In Bandwidth : %v - %v (> %v%%)`,
-			convert.HumanReadable(*intNewData.IfInRate, 1024, "bits/sec"),
-			sknchk.FmtCritical(fmt.Sprintf("%.2f%%", *intNewData.IfInPrct)), bc),
-			true)
-		chk.AddCritical()
-	} else if intNewData.IfInPrct != nil && *intNewData.IfInPrct > bw {
-		chk.AddShort(fmt.Sprintf(`High In Bandwidth : %v - %v (> %v%%)`,
-			convert.HumanReadable(*intNewData.IfInRate, 1024, "bits/sec"),
-			sknchk.FmtWarning(fmt.Sprintf("%.2f%%", *intNewData.IfInPrct)), bw),
-			true)
-		chk.AddWarning()
+	bandwidthThresholds(chk, "In", "in", intNewData.IfInRate, intNewData.IfInPrct, in, eval)
+	bandwidthThresholds(chk, "Out", "out", intNewData.IfOutRate, intNewData.IfOutPrct, out, eval)
+	return nil
+}
+
+//bandwidthThresholds test the rate or the usage of one direction of the interface against its thresholds,
+//depending of their unit (bps or %)
+func bandwidthThresholds(chk *Check, direction string, metric string, rate *float64, prct *float64, limits Limits, eval *Evaluation) {
+	rate, prct = eval.Value(metric, rate), eval.Value(metric+"_usage", prct)
+	tested, testedMetric, format := prct, metric+"_usage", func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) + "%" }
+	if limits.Unit == "bps" {
+		tested, testedMetric, format = rate, metric, func(f float64) string { return convert.HumanReadable(f, 1000, "bps") }
+	}
+	limit, critical, held, ok := eval.test(testedMetric, tested, limits)
+	if !ok {
+		return
+	}
+
+	rateStr := convert.HumanReadable(*rate, 1024, "bits/sec")
+	prctStr := "N/A"
+	if prct != nil {
+		prctStr = fmt.Sprintf("%.2f%%", *prct)
+	}
+	if limits.Unit == "bps" {
+		rateStr = fmtStatus(rateStr, critical)
+	} else {
+		prctStr = fmtStatus(prctStr, critical)
+	}
+	chk.AddShort(fmt.Sprintf(`%v %v Bandwidth%v : %v - %v (%v)`, limit.qualify(*tested, critical), direction, eval.Label(),
+		rateStr, prctStr, limit.describeFunc(*tested, format, held)),
+		true)
+	addStatus(chk, critical)
+}
+
+//packetThresholds test the rate or the percentage of packets in error or discard of one direction of the interface
+//against its thresholds, depending of their unit (pps or %)
+func packetThresholds(chk *Check, label string, metric string, rate *float64, prct *float64, limits Limits, eval *Evaluation) {
+	rate, prct = eval.Value(metric, rate), eval.Value(metric+"_prct", prct)
+	tested, testedMetric, unit := rate, metric, " pps"
+	if limits.Unit == "%" {
+		tested, testedMetric, unit = prct, metric+"_prct", " %"
+	}
+	limit, critical, held, ok := eval.test(testedMetric, tested, limits)
+	if !ok {
+		return
+	}
+
+	rateStr, prctStr := "N/A", "N/A"
+	if rate != nil {
+		rateStr = fmt.Sprintf("%.2f pps", *rate)
+	}
+	if prct != nil {
+		prctStr = fmt.Sprintf("%.2f %%", *prct)
 	}
+	if limits.Unit == "%" {
+		prctStr = fmtStatus(prctStr, critical)
+	} else {
+		rateStr = fmtStatus(rateStr, critical)
+	}
+	chk.AddShort(fmt.Sprintf(`%v %v%v : %v - %v (%v)`, limit.qualify(*tested, critical), label, eval.Label(),
+		rateStr, prctStr, limit.describe(*tested, unit, held)),
+		true)
+	addStatus(chk, critical)
+}
+
+//fmtStatus format the value exceeding a critical or a warning threshold
+func fmtStatus(value string, critical bool) string {
+	if critical {
+		return sknchk.FmtCritical(value)
+	}
+	return sknchk.FmtWarning(value)
+}
 
-	if intNewData.IfOutPrct != nil && *intNewData.IfOutPrct > bc {
-		chk.AddShort(fmt.Sprintf(`Very high Out Bandwidth : %v - %v (> %v%%)`,
-			convert.HumanReadable(*intNewData.IfOutRate, 1024, "bits/sec"),
-			sknchk.FmtCritical(fmt.Sprintf("%.2f%%", *intNewData.IfOutPrct)), bc),
-			true)
+//addStatus add the Critical or the Warning status to the check
+func addStatus(chk *Check, critical bool) {
+	if critical {
 		chk.AddCritical()
-	} else if intNewData.IfOutPrct != nil && *intNewData.IfOutPrct > bw {
-		chk.AddShort(fmt.Sprintf(`High Out Bandwidth : %v - %v (> %v%%)`,
-			convert.HumanReadable(*intNewData.IfOutRate, 1024, "bits/sec"),
-			sknchk.FmtWarning(fmt.Sprintf("%.2f%%", *intNewData.IfOutPrct)), bw),
-			true)
+	} else {
 		chk.AddWarning()
 	}
 }
 
 //Packets returns the rate in pps of the total, unicast, multicast and broadcast packets
-func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration) {
+func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration) error {
 	log.Debug("===== Total pckts =====")
 	var inUniPckt uint
 	var inMultiPckt uint
@@ -265,27 +399,27 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 	var err error
 	log.Debug("==> In Unicast pckts")
 	if intNewData.IfHCInUcastPkts != nil {
-		inUniPckt, err = diff(intNewData.IfHCInUcastPkts, intOldData.IfHCInUcastPkts, true)
+		inUniPckt, err = diff(intNewData.IfHCInUcastPkts, intOldData.IfHCInUcastPkts, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCInUcastPkts counter available, skip...")
 	}
 	log.Debug("==> In Multicast pckts")
 	if intNewData.IfHCInMulticastPkts != nil {
-		inMultiPckt, err = diff(intNewData.IfHCInMulticastPkts, intOldData.IfHCInMulticastPkts, true)
+		inMultiPckt, err = diff(intNewData.IfHCInMulticastPkts, intOldData.IfHCInMulticastPkts, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCInMulticastPkts counter available, skip...")
 	}
 	log.Debug("==> In Broadcast pckts")
 	if intNewData.IfHCInBroadcastPkts != nil {
-		inBroadPckt, err = diff(intNewData.IfHCInBroadcastPkts, intOldData.IfHCInBroadcastPkts, true)
+		inBroadPckt, err = diff(intNewData.IfHCInBroadcastPkts, intOldData.IfHCInBroadcastPkts, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCInBroadcastPkts counter available, skip...")
@@ -295,18 +429,18 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 	if intNewData.IfHCInUcastPkts == nil {
 		log.Debug("No IfHCInUcastPkts found, switch to 32 bits counter...")
 		if intNewData.IfInUcastPkts != nil {
-			inUniPckt, err = diff(intNewData.IfInUcastPkts, intOldData.IfInUcastPkts, false)
+			inUniPckt, err = diff(intNewData.IfInUcastPkts, intOldData.IfInUcastPkts, false, intNewData.BwInconsistency)
 			if err != nil {
-				sknchk.Unknown(fmt.Sprint(err), "")
+				return err
 			}
 		} else {
 			log.Debug("No IfInUcastPkts counter available, skip...")
 		}
 		log.Debug("==> In Multicast pckts")
 		if intNewData.IfInNUcastPkts != nil {
-			inMultiPckt, err = diff(intNewData.IfInNUcastPkts, intOldData.IfInNUcastPkts, false)
+			inMultiPckt, err = diff(intNewData.IfInNUcastPkts, intOldData.IfInNUcastPkts, false, intNewData.BwInconsistency)
 			if err != nil {
-				sknchk.Unknown(fmt.Sprint(err), "")
+				return err
 			}
 		} else {
 			log.Debug("No IfInNUcastPkts counter available, skip...")
@@ -335,27 +469,27 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 	var outBroadPckt uint
 	log.Debug("==> Out Unicast pckts")
 	if intNewData.IfHCOutUcastPkts != nil {
-		outUniPckt, err = diff(intNewData.IfHCOutUcastPkts, intOldData.IfHCOutUcastPkts, true)
+		outUniPckt, err = diff(intNewData.IfHCOutUcastPkts, intOldData.IfHCOutUcastPkts, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCOutUcastPkts counter available, skip...")
 	}
 	log.Debug("==> Out Multicast pckts")
 	if intNewData.IfHCOutMulticastPkts != nil {
-		outMultiPckt, err = diff(intNewData.IfHCOutMulticastPkts, intOldData.IfHCOutMulticastPkts, true)
+		outMultiPckt, err = diff(intNewData.IfHCOutMulticastPkts, intOldData.IfHCOutMulticastPkts, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCOutMulticastPkts counter available, skip...")
 	}
 	log.Debug("==> Out Broadcast pckts")
 	if intNewData.IfHCOutBroadcastPkts != nil {
-		outBroadPckt, err = diff(intNewData.IfHCOutBroadcastPkts, intOldData.IfHCOutBroadcastPkts, true)
+		outBroadPckt, err = diff(intNewData.IfHCOutBroadcastPkts, intOldData.IfHCOutBroadcastPkts, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCOutBroadcastPkts counter available, skip...")
@@ -365,18 +499,18 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 	if intNewData.IfHCOutUcastPkts == nil {
 		log.Debug("No IfHCOutUcastPkts found, switch to 32 bits counter...")
 		if intNewData.IfOutUcastPkts != nil {
-			outUniPckt, err = diff(intNewData.IfOutUcastPkts, intOldData.IfOutUcastPkts, false)
+			outUniPckt, err = diff(intNewData.IfOutUcastPkts, intOldData.IfOutUcastPkts, false, intNewData.BwInconsistency)
 			if err != nil {
-				sknchk.Unknown(fmt.Sprint(err), "")
+				return err
 			}
 		} else {
 			log.Debug("No IfInUcastPkts counter available, skip...")
 		}
 		log.Debug("==> In Multicast pckts")
 		if intNewData.IfOutNUcastPkts != nil {
-			outMultiPckt, err = diff(intNewData.IfOutNUcastPkts, intOldData.IfOutNUcastPkts, false)
+			outMultiPckt, err = diff(intNewData.IfOutNUcastPkts, intOldData.IfOutNUcastPkts, false, intNewData.BwInconsistency)
 			if err != nil {
-				sknchk.Unknown(fmt.Sprint(err), "")
+				return err
 			}
 		} else {
 			log.Debug("No IfInNUcastPkts counter available, skip...")
@@ -402,9 +536,9 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 
 	log.Debug("===== In Unicast =====")
 	if intNewData.IfHCInUcastPkts != nil {
-		intNewData.InUniPcktRate, _, err = pckStats(intNewData.IfHCInUcastPkts, intOldData.IfHCInUcastPkts, intNewData.IfInTotalPkts, timeDiff, true)
+		intNewData.InUniPcktRate, _, err = pckStats(intNewData.IfHCInUcastPkts, intOldData.IfHCInUcastPkts, intNewData.IfInTotalPkts, timeDiff, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCInUcastPkts counter available, skip...")
@@ -412,9 +546,9 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 
 	log.Debug("===== In Multicast =====")
 	if intNewData.IfHCInMulticastPkts != nil {
-		intNewData.InMultiPcktRate, _, err = pckStats(intNewData.IfHCInMulticastPkts, intOldData.IfHCInMulticastPkts, intNewData.IfInTotalPkts, timeDiff, true)
+		intNewData.InMultiPcktRate, _, err = pckStats(intNewData.IfHCInMulticastPkts, intOldData.IfHCInMulticastPkts, intNewData.IfInTotalPkts, timeDiff, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCInMulticastPkts counter available, skip...")
@@ -422,9 +556,9 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 
 	log.Debug("===== In Broadcast =====")
 	if intNewData.IfHCInBroadcastPkts != nil {
-		intNewData.InBroadPcktRate, _, err = pckStats(intNewData.IfHCInBroadcastPkts, intOldData.IfHCInBroadcastPkts, intNewData.IfInTotalPkts, timeDiff, true)
+		intNewData.InBroadPcktRate, _, err = pckStats(intNewData.IfHCInBroadcastPkts, intOldData.IfHCInBroadcastPkts, intNewData.IfInTotalPkts, timeDiff, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCInBroadcastPkts counter available, skip...")
@@ -432,9 +566,9 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 
 	log.Debug("===== Out Unicast =====")
 	if intNewData.IfHCOutUcastPkts != nil {
-		intNewData.OutUniPcktRate, _, err = pckStats(intNewData.IfHCOutUcastPkts, intOldData.IfHCOutUcastPkts, intNewData.IfOutTotalPkts, timeDiff, true)
+		intNewData.OutUniPcktRate, _, err = pckStats(intNewData.IfHCOutUcastPkts, intOldData.IfHCOutUcastPkts, intNewData.IfOutTotalPkts, timeDiff, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCOutUcastPkts counter available, skip...")
@@ -442,9 +576,9 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 
 	log.Debug("===== Out Multicast =====")
 	if intNewData.IfHCOutMulticastPkts != nil {
-		intNewData.OutMultiPcktRate, _, err = pckStats(intNewData.IfHCOutMulticastPkts, intOldData.IfHCOutMulticastPkts, intNewData.IfOutTotalPkts, timeDiff, true)
+		intNewData.OutMultiPcktRate, _, err = pckStats(intNewData.IfHCOutMulticastPkts, intOldData.IfHCOutMulticastPkts, intNewData.IfOutTotalPkts, timeDiff, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCOutMulticastPkts counter available, skip...")
@@ -452,9 +586,9 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 
 	log.Debug("===== Out Broadcast =====")
 	if intNewData.IfHCOutBroadcastPkts != nil {
-		intNewData.OutBroadPcktRate, _, err = pckStats(intNewData.IfHCOutBroadcastPkts, intOldData.IfHCOutBroadcastPkts, intNewData.IfOutTotalPkts, timeDiff, true)
+		intNewData.OutBroadPcktRate, _, err = pckStats(intNewData.IfHCOutBroadcastPkts, intOldData.IfHCOutBroadcastPkts, intNewData.IfOutTotalPkts, timeDiff, true, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfHCOutBroadcastPkts counter available, skip...")
@@ -465,9 +599,9 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 		log.Debug("No IfHCInUcastPkts found, switch to 32 bits counter...")
 		if intNewData.IfInUcastPkts != nil {
 			log.Debug("===== In Unicast 32 bits =====")
-			intNewData.InUniPcktRate, _, err = pckStats(intNewData.IfInUcastPkts, intOldData.IfInUcastPkts, intNewData.IfInTotalPkts, timeDiff, true)
+			intNewData.InUniPcktRate, _, err = pckStats(intNewData.IfInUcastPkts, intOldData.IfInUcastPkts, intNewData.IfInTotalPkts, timeDiff, true, intNewData.BwInconsistency)
 			if err != nil {
-				sknchk.Unknown(fmt.Sprint(err), "")
+				return err
 			}
 		} else {
 			log.Debug("No IfInUcastPkts counter available, skip...")
@@ -475,9 +609,9 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 
 		if intNewData.IfInNUcastPkts != nil {
 			log.Debug("===== In Multicast 32 bits =====")
-			intNewData.InMultiPcktRate, _, err = pckStats(intNewData.IfInNUcastPkts, intOldData.IfInNUcastPkts, intNewData.IfInTotalPkts, timeDiff, true)
+			intNewData.InMultiPcktRate, _, err = pckStats(intNewData.IfInNUcastPkts, intOldData.IfInNUcastPkts, intNewData.IfInTotalPkts, timeDiff, true, intNewData.BwInconsistency)
 			if err != nil {
-				sknchk.Unknown(fmt.Sprint(err), "")
+				return err
 			}
 		} else {
 			log.Debug("No IfInNUcastPkts counter available, skip...")
@@ -488,9 +622,9 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 		log.Debug("No IfHCOutUcastPkts found, switch to 32 bits counter...")
 		if intNewData.IfOutUcastPkts != nil {
 			log.Debug("===== Out Unicast 32 bits =====")
-			intNewData.OutUniPcktRate, _, err = pckStats(intNewData.IfOutUcastPkts, intOldData.IfOutUcastPkts, intNewData.IfOutTotalPkts, timeDiff, true)
+			intNewData.OutUniPcktRate, _, err = pckStats(intNewData.IfOutUcastPkts, intOldData.IfOutUcastPkts, intNewData.IfOutTotalPkts, timeDiff, true, intNewData.BwInconsistency)
 			if err != nil {
-				sknchk.Unknown(fmt.Sprint(err), "")
+				return err
 			}
 		} else {
 			log.Debug("No IfOutUcastPkts counter available, skip...")
@@ -498,33 +632,35 @@ func Packets(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDif
 
 		if intNewData.IfOutNUcastPkts != nil {
 			log.Debug("===== Out Multicast 32 bits =====")
-			intNewData.OutMultiPcktRate, _, err = pckStats(intNewData.IfOutNUcastPkts, intOldData.IfOutNUcastPkts, intNewData.IfOutTotalPkts, timeDiff, true)
+			intNewData.OutMultiPcktRate, _, err = pckStats(intNewData.IfOutNUcastPkts, intOldData.IfOutNUcastPkts, intNewData.IfOutTotalPkts, timeDiff, true, intNewData.BwInconsistency)
 			if err != nil {
-				sknchk.Unknown(fmt.Sprint(err), "")
+				return err
 			}
 		} else {
 			log.Debug("No IfOutNUcastPkts counter available, skip...")
 		}
 	}
+	return nil
 }
 
-//Errors returns the rate in pps and the % of packets in error, the related perfdata and make the test with the thresholds to update the check
-func Errors(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration, chk *sknchk.Check, ew float64, ec float64, ev string) {
+//Errors returns the rate in pps and the % of packets in error, the related perfdata and make the test with the thresholds to update the check.
+//The error counters of the EtherLike-MIB are tested against the dot3 limits.
+func Errors(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration, chk *Check, in Limits, out Limits, dot3 Limits, eval *Evaluation) error {
 	log.Debug("===== IfInErrors =====")
 	var err error
 	if intNewData.IfInErrors != nil {
-		intNewData.IfInErrorsRate, intNewData.IfInErrorsPrct, err = pckStats(intNewData.IfInErrors, intOldData.IfInErrors, intNewData.IfInTotalPkts, timeDiff, false)
+		intNewData.IfInErrorsRate, intNewData.IfInErrorsPrct, err = pckStats(intNewData.IfInErrors, intOldData.IfInErrors, intNewData.IfInTotalPkts, timeDiff, false, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfInErrors counter available, skip...")
 	}
 
 	if intNewData.LocIfInCRC != nil {
-		intNewData.LocIfInCRCRate, intNewData.LocIfInCRCPrct, err = pckStats(intNewData.LocIfInCRC, intOldData.LocIfInCRC, intNewData.IfInTotalPkts, timeDiff, false)
+		intNewData.LocIfInCRCRate, intNewData.LocIfInCRCPrct, err = pckStats(intNewData.LocIfInCRC, intOldData.LocIfInCRC, intNewData.IfInTotalPkts, timeDiff, false, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No LocIfInCRC counter available, skip...")
@@ -532,186 +668,126 @@ func Errors(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff
 
 	log.Debug("===== IfOutErrors =====")
 	if intNewData.IfOutErrors != nil {
-		intNewData.IfOutErrorsRate, intNewData.IfOutErrorsPrct, err = pckStats(intNewData.IfOutErrors, intOldData.IfOutErrors, intNewData.IfOutTotalPkts, timeDiff, false)
+		intNewData.IfOutErrorsRate, intNewData.IfOutErrorsPrct, err = pckStats(intNewData.IfOutErrors, intOldData.IfOutErrors, intNewData.IfOutTotalPkts, timeDiff, false, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfOutErrors counter available, skip...")
 	}
 
+	warn, crit := perfThresholds("pps", in)
 	if intNewData.IfInErrorsRate != nil {
-		chk.AddPerfData("in_errors", strconv.FormatFloat(*intNewData.IfInErrorsRate, 'f', 2, 64), "pps", 0, 0, 0, 0)
+		chk.AddPerfData("in_errors", strconv.FormatFloat(*intNewData.IfInErrorsRate, 'f', 2, 64), "pps", warn, crit, 0, 0)
 	}
 
 	/* if intNewData.IfInErrorsPrct != nil {
 		chk.AddPerfData("in_errors_prct", strconv.FormatFloat(*intNewData.IfInErrorsPrct, 'f', 2, 64), "%", 0, 0, 0, 0)
 	} */
 
+	warn, crit = perfThresholds("pps", out)
 	if intNewData.IfOutErrorsRate != nil {
-		chk.AddPerfData("out_errors", strconv.FormatFloat(*intNewData.IfOutErrorsRate, 'f', 2, 64), "pps", 0, 0, 0, 0)
+		chk.AddPerfData("out_errors", strconv.FormatFloat(*intNewData.IfOutErrorsRate, 'f', 2, 64), "pps", warn, crit, 0, 0)
 	}
 
 	/* if intNewData.IfOutErrorsPrct != nil {
 		chk.AddPerfData("out_errors_prct", strconv.FormatFloat(*intNewData.IfOutErrorsPrct, 'f', 2, 64), "%", 0, 0, 0, 0)
 	} */
 
-	//Error thresholds
-	if ev == "pps" {
-		if intNewData.IfInErrorsRate != nil && *intNewData.IfInErrorsRate > ec {
-			chk.AddShort(fmt.Sprintf(`Very high In Errors : %v - %.2f %% (> %v %v)`,
-				sknchk.FmtCritical(fmt.Sprintf("%.2f pps", *intNewData.IfInErrorsRate)),
-				*intNewData.IfInErrorsPrct, ec, ev),
-				true)
-			chk.AddCritical()
-		} else if intNewData.IfInErrorsRate != nil && *intNewData.IfInErrorsRate > ew {
-			chk.AddShort(fmt.Sprintf(`High In Errors : %v - %.2f %% (> %v %v)`,
-				sknchk.FmtWarning(fmt.Sprintf("%.2f pps", *intNewData.IfInErrorsRate)),
-				*intNewData.IfInErrorsPrct, ew, ev),
-				true)
-			chk.AddWarning()
-		}
-		if intNewData.IfOutErrorsRate != nil && *intNewData.IfOutErrorsRate > ec {
-			chk.AddShort(fmt.Sprintf(`Very high Out Errors : %v - %.2f %% (> %v %v)`,
-				sknchk.FmtCritical(fmt.Sprintf("%.2f pps", *intNewData.IfOutErrorsRate)),
-				*intNewData.IfOutErrorsPrct, ec, ev),
-				true)
-			chk.AddCritical()
-		} else if intNewData.IfOutErrorsRate != nil && *intNewData.IfOutErrorsRate > ew {
-			chk.AddShort(fmt.Sprintf(`High Out Errors : %v - %.2f %% (> %v %v)`,
-				sknchk.FmtWarning(fmt.Sprintf("%.2f pps", *intNewData.IfOutErrorsRate)),
-				*intNewData.IfOutErrorsPrct, ew, ev),
-				true)
-			chk.AddWarning()
-		}
-	} else {
-		if intNewData.IfInErrorsPrct != nil && *intNewData.IfInErrorsPrct > ec {
-			chk.AddShort(fmt.Sprintf(`Very high In Errors : %.2f pps - %v (> %v %v)`,
-				*intNewData.IfInErrorsRate,
-				sknchk.FmtCritical(fmt.Sprintf("%.2f %%", *intNewData.IfInErrorsPrct)), ec, ev),
-				true)
-			chk.AddCritical()
-		} else if intNewData.IfInErrorsPrct != nil && *intNewData.IfInErrorsPrct > ew {
-			chk.AddShort(fmt.Sprintf(`High In Errors : %.2f pps - %v (> %v %v)`,
-				*intNewData.IfInErrorsRate,
-				sknchk.FmtWarning(fmt.Sprintf("%.2f %%", *intNewData.IfInErrorsPrct)), ew, ev),
-				true)
-			chk.AddWarning()
-		}
-		if intNewData.IfOutErrorsPrct != nil && *intNewData.IfOutErrorsPrct > ec {
-			chk.AddShort(fmt.Sprintf(`Very high Out Errors : %.2f pps - %v (> %v %v)`,
-				*intNewData.IfOutErrorsRate,
-				sknchk.FmtCritical(fmt.Sprintf("%.2f %%", *intNewData.IfOutErrorsPrct)), ec, ev),
-				true)
-			chk.AddCritical()
-		} else if intNewData.IfOutErrorsPrct != nil && *intNewData.IfOutErrorsPrct > ew {
-			chk.AddShort(fmt.Sprintf(`High Out Errors : %.2f pps - %v (> %v %v)`,
-				*intNewData.IfOutErrorsRate,
-				sknchk.FmtWarning(fmt.Sprintf("%.2f %%", *intNewData.IfOutErrorsPrct)), ew, ev),
-				true)
-			chk.AddWarning()
-		}
-	}
+	packetThresholds(chk, "In Errors", "in_errors", intNewData.IfInErrorsRate, intNewData.IfInErrorsPrct, in, eval)
+	packetThresholds(chk, "Out Errors", "out_errors", intNewData.IfOutErrorsRate, intNewData.IfOutErrorsPrct, out, eval)
+
+	return dot3Stats(dot3Counters, intNewData, intOldData, timeDiff, chk, dot3, eval)
 }
 
 //Discards returns the rate in pps and the % of packets in discard, the related perfdata and make the test with the thresholds to update the check
-func Discards(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration, chk *sknchk.Check, dw float64, dc float64, dv string) {
+func Discards(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration, chk *Check, in Limits, out Limits, eval *Evaluation) error {
 	log.Debug("===== IfInDiscards =====")
 	var err error
 	if intNewData.IfInDiscards != nil {
-		intNewData.IfInDiscardsRate, intNewData.IfInDiscardsPrct, err = pckStats(intNewData.IfInDiscards, intOldData.IfInDiscards, intNewData.IfInTotalPkts, timeDiff, false)
+		intNewData.IfInDiscardsRate, intNewData.IfInDiscardsPrct, err = pckStats(intNewData.IfInDiscards, intOldData.IfInDiscards, intNewData.IfInTotalPkts, timeDiff, false, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfInDiscards counter available, skip...")
 	}
 	log.Debug("===== IfOutDiscards =====")
 	if intNewData.IfOutDiscards != nil {
-		intNewData.IfOutDiscardsRate, intNewData.IfOutDiscardsPrct, err = pckStats(intNewData.IfOutDiscards, intOldData.IfOutDiscards, intNewData.IfOutTotalPkts, timeDiff, false)
+		intNewData.IfOutDiscardsRate, intNewData.IfOutDiscardsPrct, err = pckStats(intNewData.IfOutDiscards, intOldData.IfOutDiscards, intNewData.IfOutTotalPkts, timeDiff, false, intNewData.BwInconsistency)
 		if err != nil {
-			sknchk.Unknown(fmt.Sprint(err), "")
+			return err
 		}
 	} else {
 		log.Debug("No IfOutDiscards counter available, skip...")
 	}
 
+	warn, crit := perfThresholds("pps", in)
 	if intNewData.IfInDiscardsRate != nil {
-		chk.AddPerfData("in_discards", strconv.FormatFloat(*intNewData.IfInDiscardsRate, 'f', 2, 64), "pps", 0, 0, 0, 0)
+		chk.AddPerfData("in_discards", strconv.FormatFloat(*intNewData.IfInDiscardsRate, 'f', 2, 64), "pps", warn, crit, 0, 0)
 	}
 
 	/* if intNewData.IfInDiscardsPrct != nil {
 		chk.AddPerfData("in_discards_prct", strconv.FormatFloat(*intNewData.IfInDiscardsPrct, 'f', 2, 64), "%", 0, 0, 0, 0)
 	} */
 
+	warn, crit = perfThresholds("pps", out)
 	if intNewData.IfOutDiscardsRate != nil {
-		chk.AddPerfData("out_discards", strconv.FormatFloat(*intNewData.IfOutDiscardsRate, 'f', 2, 64), "pps", 0, 0, 0, 0)
+		chk.AddPerfData("out_discards", strconv.FormatFloat(*intNewData.IfOutDiscardsRate, 'f', 2, 64), "pps", warn, crit, 0, 0)
 	}
 
 	/* if intNewData.IfOutDiscardsPrct != nil {
 		chk.AddPerfData("out_discards_prct", strconv.FormatFloat(*intNewData.IfOutDiscardsPrct, 'f', 2, 64), "%", 0, 0, 0, 0)
 	} */
 
-	if dv == "pps" {
-		if intNewData.IfInDiscardsRate != nil && *intNewData.IfInDiscardsRate > dc {
-			chk.AddShort(fmt.Sprintf(`Very high In Discards : %v - %.2f %% (> %v %v)`,
-				sknchk.FmtCritical(fmt.Sprintf("%.2f pps", *intNewData.IfInDiscardsRate)),
-				*intNewData.IfInDiscardsPrct, dc, dv),
-				true)
-			chk.AddCritical()
-		} else if intNewData.IfInDiscardsRate != nil && *intNewData.IfInDiscardsRate > dw {
-			chk.AddShort(fmt.Sprintf(`High In Discards : %v - %.2f %% (> %v %v)`,
-				sknchk.FmtWarning(fmt.Sprintf("%.2f pps", *intNewData.IfInDiscardsRate)),
-				*intNewData.IfInDiscardsPrct, dw, dv),
-				true)
-			chk.AddWarning()
-		}
-		if intNewData.IfOutDiscardsRate != nil && *intNewData.IfOutDiscardsRate > dc {
-			chk.AddShort(fmt.Sprintf(`Very high Out Discards : %v - %.2f %% (> %v %v)`,
-				sknchk.FmtCritical(fmt.Sprintf("%.2f pps", *intNewData.IfOutDiscardsRate)),
-				*intNewData.IfOutDiscardsPrct, dc, dv),
-				true)
-			chk.AddCritical()
-		} else if intNewData.IfOutDiscardsRate != nil && *intNewData.IfOutDiscardsRate > dw {
-			chk.AddShort(fmt.Sprintf(`High Out Errors : %v - %.2f %% (> %v %v)`,
-				sknchk.FmtWarning(fmt.Sprintf("%.2f pps", *intNewData.IfOutDiscardsRate)),
-				*intNewData.IfOutDiscardsPrct, dw, dv),
-				true)
-			chk.AddWarning()
-		}
-	} else {
-		if intNewData.IfInDiscardsPrct != nil && *intNewData.IfInDiscardsPrct > dc {
-			chk.AddShort(fmt.Sprintf(`Very high In Discards : %.2f pps - %v (> %v %v)`,
-				*intNewData.IfInDiscardsRate,
-				sknchk.FmtCritical(fmt.Sprintf("%.2f %%", *intNewData.IfInDiscardsPrct)), dc, dv),
-				true)
-			chk.AddCritical()
-		} else if intNewData.IfInDiscardsPrct != nil && *intNewData.IfInDiscardsPrct > dw {
-			chk.AddShort(fmt.Sprintf(`High In Discards : %.2f pps - %v (> %v %v)`,
-				*intNewData.IfInDiscardsRate,
-				sknchk.FmtWarning(fmt.Sprintf("%.2f %%", *intNewData.IfInDiscardsPrct)), dw, dv),
-				true)
-			chk.AddWarning()
-		}
-		if intNewData.IfOutDiscardsPrct != nil && *intNewData.IfOutDiscardsPrct > dc {
-			chk.AddShort(fmt.Sprintf(`Very high Out Discards : %.2f pps - %v (> %v %v)`,
-				*intNewData.IfOutDiscardsRate,
-				sknchk.FmtCritical(fmt.Sprintf("%.2f %%", *intNewData.IfOutDiscardsPrct)), dc, dv),
-				true)
-			chk.AddCritical()
-		} else if intNewData.IfOutDiscardsPrct != nil && *intNewData.IfOutDiscardsPrct > dw {
-			chk.AddShort(fmt.Sprintf(`High Out Discards : %.2f pps - %v (> %v %v)`,
-				*intNewData.IfOutDiscardsRate,
-				sknchk.FmtWarning(fmt.Sprintf("%.2f %%", *intNewData.IfOutDiscardsPrct)), dw, dv),
-				true)
-			chk.AddWarning()
-		}
-	}
+	packetThresholds(chk, "In Discards", "in_discards", intNewData.IfInDiscardsRate, intNewData.IfInDiscardsPrct, in, eval)
+	packetThresholds(chk, "Out Discards", "out_discards", intNewData.IfOutDiscardsRate, intNewData.IfOutDiscardsPrct, out, eval)
+	return nil
+}
+
+//SpeedOverride replaces the speed reported by the device in the usage computation, 0 keeps the reported speed.
+//The speed can be different for each direction (DSL...)
+type SpeedOverride struct {
+	In  uint
+	Out uint
 }
 
-//Speed returns the interface speed in bps and the related perfdata
-func Speed(intNewData *InterfaceDetails, chk *sknchk.Check) {
+//Speed returns the interface speed in bps and the related perfdata, and the speed of each direction with the override applied
+func Speed(intNewData *InterfaceDetails, chk *Check, override SpeedOverride) {
 	log.Debug("===== Speed =====")
+	speed := reportedSpeed(intNewData)
+	//The aggregations like the bonds generally have no speed, the capacity of their active members is used instead
+	if speed == 0 && intNewData.Lag != nil && intNewData.Lag.ActiveCapacity() > 0 {
+		speed = intNewData.Lag.ActiveCapacity()
+		log.Debugf("No speed reported, use the capacity of the active members of the aggregation : %v bps", speed)
+	}
+	//A subinterface shares the capacity of its physical parent, the speed of a virtual interface without parent is nominal
+	if parent := intNewData.Parent(); parent != nil && parent.Speed > 0 {
+		speed = parent.Speed
+		log.Debugf("Use the speed of the parent %v : %v bps", parent.Name, speed)
+	} else if intNewData.isVirtual() {
+		speed = 0
+		log.Debug("Virtual interface without parent, its speed is ignored")
+	}
+	intNewData.SpeedInbit = new(uint)
+	*intNewData.SpeedInbit = speed
+	chk.AddPerfData("speed", *intNewData.SpeedInbit, "", 0, 0, 0, 0)
+
+	inSpeed, outSpeed := speed, speed
+	if override.In > 0 {
+		log.Debugf("In speed overridden : %v bps", override.In)
+		inSpeed = override.In
+	}
+	if override.Out > 0 {
+		log.Debugf("Out speed overridden : %v bps", override.Out)
+		outSpeed = override.Out
+	}
+	intNewData.InSpeed = &inSpeed
+	intNewData.OutSpeed = &outSpeed
+}
+
+//reportedSpeed return the speed in bps reported by the device, from the ifHighSpeed or the ifSpeed
+func reportedSpeed(intNewData *InterfaceDetails) uint {
 	var speed uint
 	if intNewData.IfHighSpeed != nil {
 		log.Debug("ifHighSpeed found")
@@ -736,13 +812,11 @@ func Speed(intNewData *InterfaceDetails, chk *sknchk.Check) {
 	} else {
 		log.Debug("No speed found")
 	}
-	intNewData.SpeedInbit = new(uint)
-	*intNewData.SpeedInbit = speed
-	chk.AddPerfData("speed", *intNewData.SpeedInbit, "", 0, 0, 0, 0)
+	return speed
 }
 
 //DuplexMode returns the Duplex Mode and the related perfdata
-func DuplexMode(intNewData *InterfaceDetails, chk *sknchk.Check) {
+func DuplexMode(intNewData *InterfaceDetails, chk *Check) {
 	log.Debug("===== Duplex Mode =====")
 	//1-unknown, 2-halfDuplex, 3-fullDuplex
 	/* intNewData.Dot3StatsDuplexStatus = new(uint)
@@ -761,8 +835,9 @@ func DuplexMode(intNewData *InterfaceDetails, chk *sknchk.Check) {
 	}
 }
 
-//bwStats will return the rate and the percent usage of a specifique element
-func bwStats(newData interface{}, oldData interface{}, speed interface{}, elapseTime time.Duration, is64 bool) (*float64, *float64, error) {
+//bwStats will return the rate and the percent usage of a specifique element.
+//inconsistency is set to true if the rate isn't relevant, the packets are then computed from the new values only.
+func bwStats(newData interface{}, oldData interface{}, speed uint, elapseTime time.Duration, is64 bool, inconsistency *bool) (*float64, *float64, error) {
 	if reflect.TypeOf(newData).Elem() != reflect.TypeOf(oldData).Elem() {
 		return nil, nil, fmt.Errorf("2 different value types provided : %v, %v", reflect.TypeOf(newData), reflect.TypeOf(oldData))
 	}
@@ -807,10 +882,7 @@ func bwStats(newData interface{}, oldData interface{}, speed interface{}, elapse
 
 	log.Debugf("Rate : %v\n", convert.HumanReadable(rate, 1024, "bits/sec"))
 
-	speedConverted, err := convert.ToFloat(speed)
-	if err != nil {
-		return nil, nil, err
-	}
+	speedConverted := float64(speed)
 	prct := 0.0
 	if speedConverted > 0 {
 		prct = (rate / speedConverted) * 100
@@ -828,7 +900,7 @@ func bwStats(newData interface{}, oldData interface{}, speed interface{}, elapse
 			log.Debug("The difference will be taken from 0 to newValue")
 			//Inconsistency found, set the boolean to true to modify the packets calculation
 			log.Debug("Set BwInconsistency to true.")
-			BwInconsistency = true
+			*inconsistency = true
 			rate = 0
 			prct = 0
 			log.Debugf("New Rate : %v\n", convert.HumanReadable(rate, 1024, "bits/sec"))
@@ -842,19 +914,23 @@ func bwStats(newData interface{}, oldData interface{}, speed interface{}, elapse
 		log.Debug("The difference will be taken from 0 to newValue")
 		//Inconsistency found, set the boolean to true to modify the packets calculation
 		log.Debug("Set BwInconsistency to true.")
-		BwInconsistency = true
+		*inconsistency = true
 		rate = 0
 		prct = 0
-		log.Debugf("New Rate : %.2f\n", convert.HumanReadable(rate, 1024, "bits/sec"))
+		log.Debugf("New Rate : %v\n", convert.HumanReadable(rate, 1024, "bits/sec"))
 		log.Debugf("New Percent : %.2f %%\n", prct)
 	}
 
+	if speed == 0 {
+		//Without speed the usage is meaningless, only the rate is kept
+		return &rate, nil, nil
+	}
 	return &rate, &prct, nil
 }
 
 //pckStats will return the difference between 2 counters and the rate.
 //Used by all the pckts elements (Total, Unicast, MultiCast and Broadcast)
-func pckStats(newData *uint, oldData *uint, totalPckt *uint, elapseTime time.Duration, is64 bool) (*float64, *float64, error) {
+func pckStats(newData *uint, oldData *uint, totalPckt *uint, elapseTime time.Duration, is64 bool, inconsistency bool) (*float64, *float64, error) {
 	if reflect.TypeOf(newData) != reflect.TypeOf(oldData) {
 		return nil, nil, fmt.Errorf("2 different value types provided : %v, %v", reflect.TypeOf(newData), reflect.TypeOf(oldData))
 	}
@@ -868,7 +944,7 @@ func pckStats(newData *uint, oldData *uint, totalPckt *uint, elapseTime time.Dur
 
 	if *newData == *oldData {
 		diff = 0
-	} else if BwInconsistency {
+	} else if inconsistency {
 		log.Debug("Inconsistency found, diff based only on the new value")
 		//If Bandwidth inconsistency is found we also apply the calculation only on the new value
 		diff = 0
@@ -0,0 +1,387 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-check-network-interface/threshold"
+
+	sknchk "github.com/pandaoc-io/go-shinken-check"
+	log "github.com/sirupsen/logrus"
+)
+
+//Methods used to compute the value of the history tested against the thresholds
+const (
+	AggregateLast = "last"
+	AggregateAvg  = "avg"
+	AggregateMax  = "max"
+	AggregateP95  = "p95"
+)
+
+//DefaultHistorySize is the number of samples kept when no size is given
+const DefaultHistorySize = 30
+
+//HistoryOptions defines the size of the history kept in the state and how it's used to evaluate the thresholds
+type HistoryOptions struct {
+	//Size is the maximum number of samples kept, DefaultHistorySize by default
+	Size int
+	//Window is the maximum age of the samples kept, no limit if 0
+	Window time.Duration
+	//Method is the aggregation of the samples tested against the thresholds (last|avg|max|p95), last by default
+	Method string
+	//Retention is the minimal age of the samples kept beyond the size, to cover the longest duration of the thresholds
+	Retention time.Duration
+}
+
+//size return the maximum number of samples kept
+func (o *HistoryOptions) size() int {
+	if o.Size <= 0 {
+		return DefaultHistorySize
+	}
+	return o.Size
+}
+
+//Validate check the options of the history
+func (o *HistoryOptions) Validate() error {
+	switch o.Method {
+	case "", AggregateLast, AggregateAvg, AggregateMax, AggregateP95:
+	default:
+		return fmt.Errorf("Unknown evaluation method %v, must be last, avg, max or p95", o.Method)
+	}
+	if o.Size < 0 || o.Window < 0 {
+		return fmt.Errorf("The size and the window of the history can't be negative")
+	}
+	return nil
+}
+
+//HistorySample contains the statistics computed at a polling, by perfdata label
+type HistorySample struct {
+	Timestamp int64
+	Values    map[string]float64
+}
+
+//History is the ring buffer of the last samples of the interface, from the oldest to the newest
+type History struct {
+	Samples []HistorySample
+}
+
+//Metrics return the statistics of the interface kept in the history, by perfdata label
+func (i *InterfaceDetails) Metrics() map[string]float64 {
+	metrics := make(map[string]float64)
+	for label, value := range map[string]*float64{
+		"in":                i.IfInRate,
+		"in_usage":          i.IfInPrct,
+		"out":               i.IfOutRate,
+		"out_usage":         i.IfOutPrct,
+		"in_errors":         i.IfInErrorsRate,
+		"in_errors_prct":    i.IfInErrorsPrct,
+		"out_errors":        i.IfOutErrorsRate,
+		"out_errors_prct":   i.IfOutErrorsPrct,
+		"in_discards":       i.IfInDiscardsRate,
+		"in_discards_prct":  i.IfInDiscardsPrct,
+		"out_discards":      i.IfOutDiscardsRate,
+		"out_discards_prct": i.IfOutDiscardsPrct,
+		"status_changes":    i.StatusChanges,
+		"flaps":             i.Flaps,
+		"lag_capacity":      i.LagCapacity,
+		"lag_unbalance_in":  i.LagUnbalanceIn,
+		"lag_unbalance_out": i.LagUnbalanceOut,
+	} {
+		if value != nil {
+			metrics[label] = *value
+		}
+	}
+	v := reflect.ValueOf(i).Elem()
+	for _, counter := range allDot3Counters() {
+		if rate := v.FieldByName(counter.elem + "Rate").Interface().(*float64); rate != nil {
+			metrics[counter.label] = *rate
+		}
+		if prct := v.FieldByName(counter.elem + "Prct").Interface().(*float64); prct != nil {
+			metrics[counter.label+"_prct"] = *prct
+		}
+	}
+	for _, metric := range opticsMetrics {
+		if sensor, _ := i.Optics.Measure(metric.label, nil); sensor != nil {
+			metrics[metric.label] = sensor.Value
+		}
+	}
+	return metrics
+}
+
+//Append return the history with the new sample, the oldest samples are dropped to respect the size and the window
+func (h *History) Append(sample HistorySample, opts *HistoryOptions) *History {
+	var samples []HistorySample
+	if h != nil {
+		samples = append(samples, h.Samples...)
+	}
+	samples = append(samples, sample)
+	if opts.Window > 0 {
+		oldest := sample.Timestamp - int64(opts.Window/time.Second)
+		for len(samples) > 0 && samples[0].Timestamp < oldest {
+			samples = samples[1:]
+		}
+	}
+	if drop := len(samples) - opts.size(); drop > 0 {
+		if opts.Retention > 0 {
+			//The newest sample older than the retention is kept, the duration is held since this sample
+			oldest := sample.Timestamp - int64(opts.Retention/time.Second)
+			for drop > 0 && samples[drop].Timestamp > oldest {
+				drop--
+			}
+			if drop < len(samples)-opts.size() {
+				log.Debugf("History extended to %v samples to cover %v", len(samples)-drop, opts.Retention)
+			}
+		}
+		samples = samples[drop:]
+	}
+	return &History{Samples: samples}
+}
+
+//Values return the values of the metric of the samples taken since the given timestamp
+func (h *History) Values(metric string, since int64) []float64 {
+	var values []float64
+	if h == nil {
+		return values
+	}
+	for _, sample := range h.Samples {
+		if value, ok := sample.Values[metric]; ok && sample.Timestamp >= since {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+//aggregate compute the average, the max or the 95th percentile (nearest rank) of the values
+func aggregate(values []float64, method string) float64 {
+	switch method {
+	case AggregateAvg:
+		var sum float64
+		for _, value := range values {
+			sum += value
+		}
+		return sum / float64(len(values))
+	case AggregateMax:
+		max := values[0]
+		for _, value := range values {
+			max = math.Max(max, value)
+		}
+		return max
+	case AggregateP95:
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		return sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+	default:
+		return values[len(values)-1]
+	}
+}
+
+//Evaluation compute the values tested against the thresholds from the current polling and the history
+type Evaluation struct {
+	Options *HistoryOptions
+	//History contains the samples of the previous pollings
+	History *History
+	//Timestamp of the current polling
+	Timestamp int64
+	//states are the results of the tests of the metrics against their limits
+	states map[string]sknchk.Status
+}
+
+//State return the result of the test of the metric against its limits, with the aggregation and the durations,
+//ok if the metric hasn't been tested
+func (e *Evaluation) State(metric string) sknchk.Status {
+	if e == nil {
+		return sknchk.RcOk
+	}
+	return e.states[metric]
+}
+
+//record keep the result of the test of the metric
+func (e *Evaluation) record(metric string, state sknchk.Status) {
+	if e == nil {
+		return
+	}
+	if e.states == nil {
+		e.states = make(map[string]sknchk.Status)
+	}
+	e.states[metric] = state
+}
+
+//Value return the value of the metric tested against the thresholds, the current value if no aggregation is configured
+func (e *Evaluation) Value(metric string, current *float64) *float64 {
+	if current == nil || e == nil || e.Options == nil || e.Options.Method == "" || e.Options.Method == AggregateLast {
+		return current
+	}
+	var since int64
+	if e.Options.Window > 0 {
+		since = e.Timestamp - int64(e.Options.Window/time.Second)
+	}
+	values := append(e.History.Values(metric, since), *current)
+	value := aggregate(values, e.Options.Method)
+	return &value
+}
+
+//Sum return the sum of the current value and of the values of the metric in the history
+func (e *Evaluation) Sum(metric string, current float64) float64 {
+	if e == nil {
+		return current
+	}
+	var since int64
+	if e.Options != nil && e.Options.Window > 0 {
+		since = e.Timestamp - int64(e.Options.Window/time.Second)
+	}
+	for _, value := range e.History.Values(metric, since) {
+		current += value
+	}
+	return current
+}
+
+//Period describe the samples of the history in the output, ex: " over 15m0s"
+func (e *Evaluation) Period() string {
+	if e == nil || e.Options == nil {
+		return ""
+	}
+	if e.Options.Window > 0 {
+		return fmt.Sprintf(" over %v", e.Options.Window)
+	}
+	return fmt.Sprintf(" over the last %v pollings", e.Options.size())
+}
+
+//Label describe the aggregation in the output, empty when the current value is used
+func (e *Evaluation) Label() string {
+	if e == nil || e.Options == nil || e.Options.Method == "" || e.Options.Method == AggregateLast {
+		return ""
+	}
+	if e.Options.Window > 0 {
+		return fmt.Sprintf(" (%v over %v)", e.Options.Method, e.Options.Window)
+	}
+	return fmt.Sprintf(" (%v of the last %v pollings)", e.Options.Method, e.Options.size())
+}
+
+//Limit is a threshold which must be exceeded during a minimal duration to raise an alert
+type Limit struct {
+	Range threshold.Range
+	//Duration is the minimal duration of the condition, the alert is raised at the first polling if 0
+	Duration time.Duration
+}
+
+//String return the range of the limit followed by its duration if any, ex: "80:15m0s"
+func (l Limit) String() string {
+	if l.Duration > 0 {
+		return fmt.Sprintf("%v:%v", &l.Range, l.Duration)
+	}
+	return l.Range.String()
+}
+
+//describe return the description of the exceeded limit for the output, ex: "> 80% for 17m0s"
+func (l Limit) describe(value float64, unit string, held time.Duration) string {
+	return l.describeFunc(value, func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) + unit }, held)
+}
+
+//describeFunc is describe with the bounds of the limit formatted by the given function
+func (l Limit) describeFunc(value float64, format func(float64) string, held time.Duration) string {
+	if l.Duration > 0 {
+		return fmt.Sprintf("%v for %v", l.Range.DescribeFunc(value, format), held)
+	}
+	return l.Range.DescribeFunc(value, format)
+}
+
+//Limits are the warning and critical limits of a metric for one direction of the interface
+type Limits struct {
+	Warn Limit
+	Crit Limit
+	//Unit of the limits, % or bps for the bandwidth, % or pps for the errors and discards
+	Unit string
+	//Disabled is true when the check of the metric isn't enabled, the limits are never tested
+	Disabled bool
+}
+
+//perfThresholds return the warn and crit fields of the perfdata of a metric, empty if the limits are of another unit or disabled
+func perfThresholds(unit string, limits Limits) (string, string) {
+	if unit != limits.Unit || limits.Disabled {
+		return "", ""
+	}
+	return limits.Warn.Range.String(), limits.Crit.Range.String()
+}
+
+//qualify return the qualifier of the value in the output (Very high, Low...)
+func (l Limit) qualify(value float64, critical bool) string {
+	var qualifier string
+	switch {
+	case l.Range.Inside:
+		qualifier = "Abnormal"
+	case l.Range.Above(value):
+		qualifier = "High"
+	default:
+		qualifier = "Low"
+	}
+	if critical {
+		return "Very " + strings.ToLower(qualifier)
+	}
+	return qualifier
+}
+
+//test test the value against the critical limit then the warning limit, and return the exceeded one
+func (e *Evaluation) test(metric string, value *float64, limits Limits) (Limit, bool, time.Duration, bool) {
+	if limits.Disabled {
+		return Limit{}, false, 0, false
+	}
+	if held, ok := e.Exceeds(metric, value, limits.Crit); ok {
+		e.record(metric, sknchk.RcCritical)
+		return limits.Crit, true, held, true
+	}
+	if held, ok := e.Exceeds(metric, value, limits.Warn); ok {
+		e.record(metric, sknchk.RcWarning)
+		return limits.Warn, false, held, true
+	}
+	e.record(metric, sknchk.RcOk)
+	return Limit{}, false, 0, false
+}
+
+//Exceeds test if the value is above the limit since at least the duration of the limit.
+//The condition is held since the oldest sample of the history above the limit without interruption until now.
+func (e *Evaluation) Exceeds(metric string, value *float64, limit Limit) (time.Duration, bool) {
+	if value == nil || !limit.Range.Alert(*value) {
+		return 0, false
+	}
+	if limit.Duration == 0 {
+		return 0, true
+	}
+	if e == nil || e.History == nil {
+		return 0, false
+	}
+	since := e.Timestamp
+	for i := len(e.History.Samples) - 1; i >= 0; i-- {
+		sample := e.History.Samples[i]
+		previous, ok := sample.Values[metric]
+		if !ok || !limit.Range.Alert(previous) {
+			break
+		}
+		since = sample.Timestamp
+	}
+	held := time.Duration(e.Timestamp-since) * time.Second
+	log.Debugf("%v in alert of the range %v since %v (required : %v)", metric, &limit.Range, held, limit.Duration)
+	return held, held >= limit.Duration
+}
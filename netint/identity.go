@@ -0,0 +1,76 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	g "github.com/gosnmp/gosnmp"
+	log "github.com/sirupsen/logrus"
+)
+
+//DeviceIdentity identify the device answering behind an address, an empty field means that the agent doesn't provide it
+type DeviceIdentity struct {
+	SysObjectID string `json:"sys_object_id,omitempty"`
+	//EngineID is the hexadecimal representation of the snmpEngineID
+	EngineID string `json:"engine_id,omitempty"`
+}
+
+func (d *DeviceIdentity) String() string {
+	return fmt.Sprintf("sysObjectID %v, engine ID %v", d.SysObjectID, d.EngineID)
+}
+
+//Differs return true if the identities are known and don't match, the fields missing on one side are ignored
+func (d *DeviceIdentity) Differs(other *DeviceIdentity) bool {
+	if d == nil || other == nil {
+		return false
+	}
+	if d.SysObjectID != "" && other.SysObjectID != "" && d.SysObjectID != other.SysObjectID {
+		return true
+	}
+	return d.EngineID != "" && other.EngineID != "" && d.EngineID != other.EngineID
+}
+
+//GetDeviceIdentity retreive the sysObjectID and the snmpEngineID of the device
+func GetDeviceIdentity(snmpConnection *g.GoSNMP) (*DeviceIdentity, error) {
+	log.Debug("=====================")
+	log.Debugf("Get device identity")
+	identity := &DeviceIdentity{}
+	variables, err := getOids(snmpConnection, []string{DeviceOids["SysObjectID"], DeviceOids["SnmpEngineID"]})
+	if err != nil {
+		return nil, fmt.Errorf("Get() device identity err: %w", err)
+	}
+	for _, variable := range variables {
+		switch {
+		case variable.Name == DeviceOids["SysObjectID"] && variable.Type == g.ObjectIdentifier:
+			identity.SysObjectID = variable.Value.(string)
+		case variable.Name == DeviceOids["SnmpEngineID"] && variable.Type == g.OctetString:
+			identity.EngineID = hex.EncodeToString(variable.Value.([]byte))
+		default:
+			log.Debugf("No value for elem '%v' (type %v)", variable.Name, variable.Type)
+		}
+	}
+	//The engine ID has already been discovered by the USM in SNMP v3
+	if usm, ok := snmpConnection.SecurityParameters.(*g.UsmSecurityParameters); ok && identity.EngineID == "" {
+		identity.EngineID = hex.EncodeToString([]byte(usm.AuthoritativeEngineID))
+	}
+	log.Debugf("Device identity : %v", identity)
+	return identity, nil
+}
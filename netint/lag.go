@@ -0,0 +1,369 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-check-network-interface/convert"
+
+	g "github.com/gosnmp/gosnmp"
+	log "github.com/sirupsen/logrus"
+)
+
+//ifTypeLag is the ifType of the IEEE 802.3ad link aggregations (ieee8023adLag)
+const ifTypeLag = 161
+
+//Lag is a link aggregation (port-channel, bond...) and its members
+type Lag struct {
+	Members []*LagMember
+	//Source is the table giving the members : lag (IEEE8023-LAG-MIB) or stack (ifStackTable)
+	Source string
+}
+
+//LagMember is a member port of a link aggregation
+type LagMember struct {
+	Index      int
+	Name       string
+	OperStatus *uint `json:",omitempty"`
+	//Attached is true when the port is attached to the aggregation (dot3adAggPortAttachedAggID), always true with the ifStackTable
+	Attached bool
+	//Speed is the speed reported by the port in bps
+	Speed uint
+	//InOctets and OutOctets are the 64 bits counters if Is64, the 32 bits ones otherwise
+	InOctets  *uint `json:",omitempty"`
+	OutOctets *uint `json:",omitempty"`
+	Is64      bool
+	InRate    *float64 `json:",omitempty"`
+	OutRate   *float64 `json:",omitempty"`
+}
+
+//LagLimits are the limits of the link aggregations
+type LagLimits struct {
+	//Capacity are the limits of the capacity of the active members in % of the capacity of all the members
+	Capacity Limits
+	//Unbalance are the limits of the difference of usage in % between the most and the least loaded active members
+	Unbalance Limits
+	//Disabled is true when the aggregations aren't checked, their members aren't fetched
+	Disabled bool
+}
+
+//Active check if the member is attached to the aggregation and UP
+func (m *LagMember) Active() bool {
+	return m.Attached && m.OperStatus != nil && *m.OperStatus == 1
+}
+
+//ActiveMembers return the number of active members of the aggregation
+func (l *Lag) ActiveMembers() int {
+	var active int
+	for _, member := range l.Members {
+		if member.Active() {
+			active++
+		}
+	}
+	return active
+}
+
+//ActiveCapacity return the sum of the speeds of the active members in bps
+func (l *Lag) ActiveCapacity() uint {
+	var capacity uint
+	for _, member := range l.Members {
+		if member.Active() {
+			capacity += member.Speed
+		}
+	}
+	return capacity
+}
+
+//TotalCapacity return the sum of the speeds of all the members in bps. A member which is down often reports no speed,
+//the highest speed of the members is used for it.
+func (l *Lag) TotalCapacity() uint {
+	var capacity, highest uint
+	for _, member := range l.Members {
+		if member.Speed > highest {
+			highest = member.Speed
+		}
+	}
+	for _, member := range l.Members {
+		if member.Speed > 0 {
+			capacity += member.Speed
+		} else {
+			capacity += highest
+		}
+	}
+	return capacity
+}
+
+//member return the member with the given ifIndex, nil if not found
+func (l *Lag) member(index int) *LagMember {
+	if l == nil {
+		return nil
+	}
+	for _, member := range l.Members {
+		if member.Index == index {
+			return member
+		}
+	}
+	return nil
+}
+
+//FetchLags retrieve the members of the interfaces which are link aggregations, from the dot3adAggPortListPorts
+//of the IEEE8023-LAG-MIB or from the ifStackTable for the ieee8023adLag interfaces without it
+func FetchLags(snmpConnection *g.GoSNMP, interfaces []*InterfaceDetails) error {
+	log.Debug("=====================")
+	log.Debugf("FetchLags of %v interface(s)", len(interfaces))
+	oidToInt := make(map[string]*InterfaceDetails)
+	var oids []string
+	for _, intData := range interfaces {
+		oid := LagOids["Dot3adAggPortListPorts"] + "." + strconv.Itoa(*intData.Index)
+		oidToInt[oid] = intData
+		oids = append(oids, oid)
+	}
+	variables, err := getOidsByGroup(snmpConnection, oids)
+	if err != nil {
+		return err
+	}
+	members := make(map[*InterfaceDetails][]int)
+	for _, variable := range variables {
+		intData, ok := oidToInt["."+strings.TrimPrefix(variable.Name, ".")]
+		if !ok || variable.Type != g.OctetString {
+			continue
+		}
+		members[intData] = portList(variable.Value.([]byte))
+	}
+
+	stack, err := newIfStack(snmpConnection, false)
+	if err != nil {
+		return err
+	}
+	for _, intData := range interfaces {
+		source := "lag"
+		indexes := members[intData]
+		if len(indexes) == 0 {
+			if intData.IfType == nil || *intData.IfType != ifTypeLag {
+				continue
+			}
+			log.Debugf("No member of the aggregation %v in the IEEE8023-LAG-MIB, try the ifStackTable", *intData.Index)
+			source = "stack"
+			indexes, err = stack.lowerLayers(*intData.Index)
+			if err != nil {
+				return err
+			}
+			if len(indexes) == 0 {
+				continue
+			}
+		}
+		intData.Lag, err = fetchLagMembers(snmpConnection, *intData.Index, indexes, source)
+		if err != nil {
+			return err
+		}
+		log.Debugf("Aggregation %v : %v member(s) from the %v table", *intData.Index, len(intData.Lag.Members), source)
+	}
+	return nil
+}
+
+//portList decode a PortList, each bit set is a port number starting at 1 with the most significant bit of the first octet
+func portList(bitmap []byte) []int {
+	var ports []int
+	for i, octet := range bitmap {
+		for bit := 0; bit < 8; bit++ {
+			if octet&(0x80>>uint(bit)) != 0 {
+				ports = append(ports, i*8+bit+1)
+			}
+		}
+	}
+	return ports
+}
+
+//fetchLagMembers retrieve the status, the speed and the counters of the members of the aggregation
+func fetchLagMembers(snmpConnection *g.GoSNMP, aggIndex int, indexes []int, source string) (*Lag, error) {
+	elems := []string{"IfName", "IfDescr", "IfOperStatus", "IfSpeed", "IfHighSpeed", "IfHCInOctets", "IfHCOutOctets", "IfInOctets", "IfOutOctets"}
+	if snmpConnection.Version == g.Version1 {
+		elems = []string{"IfDescr", "IfOperStatus", "IfSpeed", "IfInOctets", "IfOutOctets"}
+	}
+	lag := &Lag{Source: source}
+	var attachedOids []string
+	for _, index := range indexes {
+		index := index
+		details := &InterfaceDetails{Index: &index}
+		err := details.GetDatas(snmpConnection, elems)
+		if err != nil {
+			return nil, err
+		}
+		member := &LagMember{Index: index, Name: memberName(details), OperStatus: details.IfOperStatus, Speed: reportedSpeed(details), Attached: true}
+		member.InOctets, member.OutOctets, member.Is64 = details.IfHCInOctets, details.IfHCOutOctets, true
+		if details.IfHCInOctets == nil || details.IfHCOutOctets == nil {
+			member.InOctets, member.OutOctets, member.Is64 = details.IfInOctets, details.IfOutOctets, false
+		}
+		lag.Members = append(lag.Members, member)
+		attachedOids = append(attachedOids, LagOids["Dot3adAggPortAttachedAggID"]+"."+strconv.Itoa(index))
+	}
+	if source != "lag" {
+		return lag, nil
+	}
+	//The port listed in the aggregation but not attached to it isn't carrying its traffic (LACP not negotiated, suspended...)
+	variables, err := getOidsByGroup(snmpConnection, attachedOids)
+	if err != nil {
+		return nil, err
+	}
+	for _, variable := range variables {
+		name := "." + strings.TrimPrefix(variable.Name, ".")
+		index, err := strconv.Atoi(name[strings.LastIndex(name, ".")+1:])
+		if err != nil {
+			continue
+		}
+		if attached, ok := pduInt(variable); ok && lag.member(index) != nil {
+			lag.member(index).Attached = attached == int64(aggIndex)
+		}
+	}
+	return lag, nil
+}
+
+//memberName return the ifName of the member, its ifDescr or its ifIndex if it has no name
+func memberName(details *InterfaceDetails) string {
+	switch {
+	case details.IfName != nil && *details.IfName != "":
+		return *details.IfName
+	case details.IfDescr != nil && *details.IfDescr != "":
+		return *details.IfDescr
+	}
+	return strconv.Itoa(*details.Index)
+}
+
+//Aggregation compute the rate of the members of a link aggregation, and test the capacity of the active members
+//and the unbalance of the load between them against their limits.
+func Aggregation(intNewData *InterfaceDetails, intOldData *InterfaceDetails, timeDiff time.Duration, chk *Check, limits LagLimits, eval *Evaluation) error {
+	log.Debug("===== Aggregation =====")
+	if limits.Disabled || intNewData.Lag == nil {
+		log.Debug("No aggregation or aggregation check disabled, skip...")
+		return nil
+	}
+	lag := intNewData.Lag
+	for _, member := range lag.Members {
+		old := intOldData.Lag.member(member.Index)
+		if old == nil || old.Is64 != member.Is64 || member.InOctets == nil || member.OutOctets == nil || old.InOctets == nil || old.OutOctets == nil {
+			continue
+		}
+		//The inconsistency of a member doesn't impact the packets of the aggregation
+		var err error
+		var inconsistency bool
+		member.InRate, _, err = bwStats(member.InOctets, old.InOctets, member.Speed, timeDiff, member.Is64, &inconsistency)
+		if err != nil {
+			return err
+		}
+		member.OutRate, _, err = bwStats(member.OutOctets, old.OutOctets, member.Speed, timeDiff, member.Is64, &inconsistency)
+		if err != nil {
+			return err
+		}
+	}
+
+	active, total := lag.ActiveCapacity(), lag.TotalCapacity()
+	chk.AddPerfData("lag_members", lag.ActiveMembers(), "", "", "", 0, len(lag.Members))
+	if total > 0 {
+		capacity := float64(active) / float64(total) * 100
+		intNewData.LagCapacity = &capacity
+		warn, crit := perfThresholds("%", limits.Capacity)
+		chk.AddPerfData("lag_capacity", strconv.FormatFloat(capacity, 'f', 2, 64), "%", warn, crit, 0, 100)
+
+		tested := eval.Value("lag_capacity", &capacity)
+		if limit, critical, held, ok := eval.test("lag_capacity", tested, limits.Capacity); ok {
+			chk.AddShort(fmt.Sprintf("%v aggregation capacity%v : %v (%v) (%v), %v/%v members active%v", limit.qualify(*tested, critical), eval.Label(),
+				fmtStatus(fmt.Sprintf("%.2f %%", *tested), critical), convert.HumanReadable(float64(active), 1000, "bps"),
+				limit.describe(*tested, "%", held), lag.ActiveMembers(), len(lag.Members), inactiveMembers(lag)),
+				true)
+			addStatus(chk, critical)
+		}
+	}
+
+	for _, direction := range []struct{ metric, name string }{{"in", "In"}, {"out", "Out"}} {
+		unbalance, detail := lagUnbalance(lag, direction.metric)
+		if unbalance == nil {
+			continue
+		}
+		if direction.metric == "in" {
+			intNewData.LagUnbalanceIn = unbalance
+		} else {
+			intNewData.LagUnbalanceOut = unbalance
+		}
+		metric := "lag_unbalance_" + direction.metric
+		warn, crit := perfThresholds("%", limits.Unbalance)
+		chk.AddPerfData(metric, strconv.FormatFloat(*unbalance, 'f', 2, 64), "%", warn, crit, 0, 100)
+
+		tested := eval.Value(metric, unbalance)
+		limit, critical, held, ok := eval.test(metric, tested, limits.Unbalance)
+		if !ok {
+			continue
+		}
+		chk.AddShort(fmt.Sprintf("%v unbalance of the %v load%v : %v (%v) between the members (%v)", limit.qualify(*tested, critical), direction.name,
+			eval.Label(), fmtStatus(fmt.Sprintf("%.2f %%", *tested), critical), limit.describe(*tested, "%", held), detail),
+			true)
+		addStatus(chk, critical)
+	}
+	return nil
+}
+
+//inactiveMembers describe the members which aren't active for the output, ex: ", inactive : Te1/1 (DOWN)"
+func inactiveMembers(lag *Lag) string {
+	var inactive []string
+	for _, member := range lag.Members {
+		if member.Active() {
+			continue
+		}
+		state := "N/A"
+		switch {
+		case member.OperStatus != nil && *member.OperStatus != 1:
+			state = OperToString(*member.OperStatus)
+		case member.OperStatus != nil:
+			state = "not attached"
+		}
+		inactive = append(inactive, fmt.Sprintf("%v (%v)", member.Name, state))
+	}
+	if len(inactive) == 0 {
+		return ""
+	}
+	return ", inactive : " + strings.Join(inactive, ", ")
+}
+
+//lagUnbalance return the difference of usage in % between the most and the least loaded active members for the direction,
+//with the usage of each of them for the output. The unbalance is nil with less than 2 active members with a rate and a speed.
+func lagUnbalance(lag *Lag, direction string) (*float64, string) {
+	min, max := math.Inf(1), math.Inf(-1)
+	var details []string
+	for _, member := range lag.Members {
+		rate := member.InRate
+		if direction == "out" {
+			rate = member.OutRate
+		}
+		if !member.Active() || rate == nil || member.Speed == 0 {
+			continue
+		}
+		usage := *rate / float64(member.Speed) * 100
+		min, max = math.Min(min, usage), math.Max(max, usage)
+		details = append(details, fmt.Sprintf("%v %.2f %%", member.Name, usage))
+	}
+	if len(details) < 2 {
+		return nil, ""
+	}
+	unbalance := max - min
+	return &unbalance, strings.Join(details, ", ")
+}
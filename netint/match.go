@@ -0,0 +1,156 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Attributes on which an interface can be selected
+const (
+	MatchByName  = "name"
+	MatchByDescr = "descr"
+	MatchByAlias = "alias"
+	MatchByIndex = "index"
+	MatchByMac   = "mac"
+)
+
+//Types of matching available to select an interface
+const (
+	MatchExact  = "exact"
+	MatchPrefix = "prefix"
+	MatchRegex  = "regex"
+)
+
+//Matcher is used to select the interfaces on one of their attributes
+type Matcher struct {
+	Pattern string
+	By      string
+	Type    string
+	re      *regexp.Regexp
+}
+
+//NewMatcher create a Matcher and check that the attribute, the type and the pattern are valid
+func NewMatcher(pattern string, by string, matchType string) (*Matcher, error) {
+	m := &Matcher{Pattern: pattern, By: by, Type: matchType}
+	switch by {
+	case MatchByName, MatchByDescr, MatchByAlias, MatchByIndex, MatchByMac:
+	default:
+		return nil, fmt.Errorf("%v is not a valid attribute to match the interface, check usage", by)
+	}
+	switch matchType {
+	case MatchExact, MatchPrefix:
+	case MatchRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid interface regex : %v", err)
+		}
+		m.re = re
+	default:
+		return nil, fmt.Errorf("%v is not a valid match type, check usage", matchType)
+	}
+	return m, nil
+}
+
+//Match check if the interface attributes (as stored into the IndexList) match the pattern
+func (m *Matcher) Match(index int, attributes map[string]string) bool {
+	var candidates []string
+	switch m.By {
+	case MatchByName:
+		//Historical behavior, the name can be the ifName or the ifDescr
+		candidates = []string{attributes["IfName"], attributes["IfDescr"]}
+	case MatchByDescr:
+		candidates = []string{attributes["IfDescr"]}
+	case MatchByAlias:
+		candidates = []string{attributes["IfAlias"]}
+	case MatchByIndex:
+		candidates = []string{strconv.Itoa(index)}
+	case MatchByMac:
+		candidates = []string{attributes["IfPhysAddress"]}
+	}
+	for _, candidate := range candidates {
+		if len(candidate) > 0 && m.matchString(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Matcher) matchString(candidate string) bool {
+	pattern := m.Pattern
+	if m.By == MatchByMac && m.Type != MatchRegex {
+		candidate = normalizeMac(candidate)
+		pattern = normalizeMac(pattern)
+	}
+	switch m.Type {
+	case MatchPrefix:
+		return strings.HasPrefix(candidate, pattern)
+	case MatchRegex:
+		return m.re.MatchString(candidate)
+	default:
+		return candidate == pattern
+	}
+}
+
+//NoSuchInterfaceError is returned when no interface of the device match the selection
+type NoSuchInterfaceError struct {
+	Matcher *Matcher
+}
+
+func (e *NoSuchInterfaceError) Error() string {
+	return fmt.Sprintf("No interface matching the selection (%v) found on the device", e.Matcher)
+}
+
+//String return a human readable description of the matcher
+func (m *Matcher) String() string {
+	return fmt.Sprintf("%v %v '%v'", m.By, m.Type, m.Pattern)
+}
+
+//Attributes return the attributes of the interface used for the matching, in the same format as the IndexList
+func (i *InterfaceDetails) Attributes() map[string]string {
+	attributes := make(map[string]string)
+	for elem, value := range map[string]*string{
+		"IfName":        i.IfName,
+		"IfDescr":       i.IfDescr,
+		"IfAlias":       i.IfAlias,
+		"IfPhysAddress": i.IfPhysAddress,
+	} {
+		if value != nil {
+			attributes[elem] = *value
+		}
+	}
+	return attributes
+}
+
+//FormatMac convert the raw ifPhysAddress octet string to the aa:bb:cc:dd:ee:ff format
+func FormatMac(raw string) string {
+	var parts []string
+	for _, b := range []byte(raw) {
+		parts = append(parts, fmt.Sprintf("%02x", b))
+	}
+	return strings.Join(parts, ":")
+}
+
+//normalizeMac remove the separators of a MAC address to compare the different notations (aa:bb.., aa-bb.., aabb.cc..)
+func normalizeMac(mac string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", "-", "", ".", "").Replace(mac))
+}
@@ -22,11 +22,13 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"go-check-network-interface/convert"
+	"go-check-network-interface/snmp"
 
+	g "github.com/gosnmp/gosnmp"
 	log "github.com/sirupsen/logrus"
-	g "github.com/soniah/gosnmp"
 )
 
 // 1-up, 2-down, 3-testing, 4-unknown, 5-dormant, 6-notPresent, 7-lowerLayerDown
@@ -42,83 +44,260 @@ const (
 
 //InterfaceDetails is the type hosting all the network interface information
 type InterfaceDetails struct {
-	UpTime                *uint
-	Timestamp             int64
-	Index                 *int
-	IfName                *string
-	IfDescr               *string
-	IfAlias               *string
-	IfSpeed               *uint
-	IfAdminStatus         *uint
-	IfOperStatus          *uint
-	IfInOctets            *uint
-	IfInRate              *float64
-	IfInPrct              *float64
-	IfInUcastPkts         *uint
-	IfInNUcastPkts        *uint
-	IfInDiscards          *uint
-	IfInDiscardsRate      *float64
-	IfInDiscardsPrct      *float64
-	IfInErrors            *uint
-	IfInErrorsRate        *float64
-	IfInErrorsPrct        *float64
-	IfOutOctets           *uint
-	IfOutRate             *float64
-	IfOutPrct             *float64
-	IfOutUcastPkts        *uint
-	IfOutNUcastPkts       *uint
-	IfOutDiscards         *uint
-	IfOutDiscardsRate     *float64
-	IfOutDiscardsPrct     *float64
-	IfOutErrors           *uint
-	IfOutErrorsRate       *float64
-	IfOutErrorsPrct       *float64
-	IfHCInOctets          *uint
-	IfHCInUcastPkts       *uint
-	IfHCInMulticastPkts   *uint
-	IfHCInBroadcastPkts   *uint
-	IfInTotalPkts         *uint
-	IfInTotalPktsRate     *float64
-	IfHCOutOctets         *uint
-	IfHCOutUcastPkts      *uint
-	IfHCOutMulticastPkts  *uint
-	IfHCOutBroadcastPkts  *uint
-	InUniPcktRate         *float64
-	InMultiPcktRate       *float64
-	InBroadPcktRate       *float64
-	OutUniPcktRate        *float64
-	OutMultiPcktRate      *float64
-	OutBroadPcktRate      *float64
-	IfOutTotalPkts        *uint
-	IfOutTotalPktsRate    *float64
-	IfHighSpeed           *uint
-	LocIfInCRC            *uint
-	LocIfInCRCRate        *float64
-	LocIfInCRCPrct        *float64
-	Dot3StatsDuplexStatus *uint
-	SpeedInbit            *uint
+	UpTime                                 *uint
+	SysUpTime                              *uint
+	Timestamp                              int64
+	Index                                  *int
+	IfName                                 *string
+	IfDescr                                *string
+	IfAlias                                *string
+	IfPhysAddress                          *string
+	IfType                                 *uint
+	IfSpeed                                *uint
+	IfAdminStatus                          *uint
+	IfOperStatus                           *uint
+	IfLastChange                           *uint
+	IfInOctets                             *uint
+	IfInRate                               *float64
+	IfInPrct                               *float64
+	IfInUcastPkts                          *uint
+	IfInNUcastPkts                         *uint
+	IfInDiscards                           *uint
+	IfInDiscardsRate                       *float64
+	IfInDiscardsPrct                       *float64
+	IfInErrors                             *uint
+	IfInErrorsRate                         *float64
+	IfInErrorsPrct                         *float64
+	IfOutOctets                            *uint
+	IfOutRate                              *float64
+	IfOutPrct                              *float64
+	IfOutUcastPkts                         *uint
+	IfOutNUcastPkts                        *uint
+	IfOutDiscards                          *uint
+	IfOutDiscardsRate                      *float64
+	IfOutDiscardsPrct                      *float64
+	IfOutErrors                            *uint
+	IfOutErrorsRate                        *float64
+	IfOutErrorsPrct                        *float64
+	IfHCInOctets                           *uint
+	IfHCInUcastPkts                        *uint
+	IfHCInMulticastPkts                    *uint
+	IfHCInBroadcastPkts                    *uint
+	IfInTotalPkts                          *uint
+	IfInTotalPktsRate                      *float64
+	IfHCOutOctets                          *uint
+	IfHCOutUcastPkts                       *uint
+	IfHCOutMulticastPkts                   *uint
+	IfHCOutBroadcastPkts                   *uint
+	InUniPcktRate                          *float64
+	InMultiPcktRate                        *float64
+	InBroadPcktRate                        *float64
+	OutUniPcktRate                         *float64
+	OutMultiPcktRate                       *float64
+	OutBroadPcktRate                       *float64
+	IfOutTotalPkts                         *uint
+	IfOutTotalPktsRate                     *float64
+	IfHighSpeed                            *uint
+	LocIfInCRC                             *uint
+	LocIfInCRCRate                         *float64
+	LocIfInCRCPrct                         *float64
+	Dot3StatsAlignmentErrors               *uint
+	Dot3StatsAlignmentErrorsRate           *float64
+	Dot3StatsAlignmentErrorsPrct           *float64
+	Dot3StatsFCSErrors                     *uint
+	Dot3StatsFCSErrorsRate                 *float64
+	Dot3StatsFCSErrorsPrct                 *float64
+	Dot3StatsInternalMacTransmitErrors     *uint
+	Dot3StatsInternalMacTransmitErrorsRate *float64
+	Dot3StatsInternalMacTransmitErrorsPrct *float64
+	Dot3StatsCarrierSenseErrors            *uint
+	Dot3StatsCarrierSenseErrorsRate        *float64
+	Dot3StatsCarrierSenseErrorsPrct        *float64
+	Dot3StatsFrameTooLongs                 *uint
+	Dot3StatsFrameTooLongsRate             *float64
+	Dot3StatsFrameTooLongsPrct             *float64
+	Dot3StatsInternalMacReceiveErrors      *uint
+	Dot3StatsInternalMacReceiveErrorsRate  *float64
+	Dot3StatsInternalMacReceiveErrorsPrct  *float64
+	Dot3StatsSymbolErrors                  *uint
+	Dot3StatsSymbolErrorsRate              *float64
+	Dot3StatsSymbolErrorsPrct              *float64
+	Dot3StatsSingleCollisionFrames         *uint
+	Dot3StatsSingleCollisionFramesRate     *float64
+	Dot3StatsSingleCollisionFramesPrct     *float64
+	Dot3StatsMultipleCollisionFrames       *uint
+	Dot3StatsMultipleCollisionFramesRate   *float64
+	Dot3StatsMultipleCollisionFramesPrct   *float64
+	Dot3StatsDeferredTransmissions         *uint
+	Dot3StatsDeferredTransmissionsRate     *float64
+	Dot3StatsDeferredTransmissionsPrct     *float64
+	Dot3StatsLateCollisions                *uint
+	Dot3StatsLateCollisionsRate            *float64
+	Dot3StatsLateCollisionsPrct            *float64
+	Dot3StatsExcessiveCollisions           *uint
+	Dot3StatsExcessiveCollisionsRate       *float64
+	Dot3StatsExcessiveCollisionsPrct       *float64
+	Dot3StatsDuplexStatus                  *uint
+	SpeedInbit                             *uint
+	//StatusChanges is 1 when the status of the interface has changed since the previous polling, 0 otherwise
+	StatusChanges *float64 `json:",omitempty"`
+	//Flaps is the number of pollings with a status change in the history, including the current one
+	Flaps *float64 `json:",omitempty"`
+	//Optics contains the measures of the optical transceiver of the interface, nil if not fetched or without transceiver
+	Optics *Optics `json:",omitempty"`
+	//Lag contains the members of the interface if it's a link aggregation, nil otherwise
+	Lag *Lag `json:",omitempty"`
+	//Stack contains the layers below the interface in the ifStackTable, from the closest one to the physical parent
+	Stack []*StackLayer `json:",omitempty"`
+	//LagCapacity is the capacity of the active members of the aggregation in % of the capacity of all its members
+	LagCapacity *float64 `json:",omitempty"`
+	//LagUnbalanceIn and LagUnbalanceOut are the differences of usage in % between the most and the least loaded active members
+	LagUnbalanceIn  *float64 `json:",omitempty"`
+	LagUnbalanceOut *float64 `json:",omitempty"`
+	//InSpeed and OutSpeed are the speeds in bps used to compute the usage of each direction,
+	//the speed reported by the device or its override
+	InSpeed  *uint `json:",omitempty"`
+	OutSpeed *uint `json:",omitempty"`
+	//History contains the statistics of the previous pollings
+	History *History `json:",omitempty"`
+	//BwInconsistency is true when the bandwidth counters are inconsistent since the previous polling,
+	//the packets are then computed from the new values only
+	BwInconsistency bool `json:"-"`
 }
 
-//GetData is used to get a specific data of a network interface.
-//The available elements can be found into the InterfaceOids Map.
-func (i *InterfaceDetails) GetData(snmpConnection *g.GoSNMP, elem string) error {
+//GetDatas is used to get several datas of a network interface in as few SNMP requests as possible.
+//The OIDs are packed by group of MaxOids elements, each varbind received is decoded back to its element.
+func (i *InterfaceDetails) GetDatas(snmpConnection *g.GoSNMP, elems []string) error {
 	log.Debug("=====================")
-	log.Debugf("GetData information : %v", elem)
-	oid := []string{InterfaceOids[elem] + "." + strconv.Itoa(*i.Index)}
-	result, err := snmpConnection.Get(oid)
+	log.Debugf("GetDatas information : %v", elems)
+	oidToElem := make(map[string]string)
+	var oids []string
+	for _, elem := range elems {
+		oid := InterfaceOids[elem] + "." + strconv.Itoa(*i.Index)
+		oidToElem[oid] = elem
+		oids = append(oids, oid)
+	}
+
+	//The variables received before an error are decoded to return the partial datas
+	variables, err := getOidsByGroup(snmpConnection, oids)
+	for _, variable := range variables {
+		elem, ok := oidToElem["."+strings.TrimPrefix(variable.Name, ".")]
+		if !ok {
+			log.Debugf("Unexpected OID '%v' received, skip...", variable.Name)
+			continue
+		}
+		i.setData(elem, variable)
+	}
+	return err
+}
+
+//getOidsByGroup send the Get requests of the oids packed by group of MaxOids elements,
+//the variables already received are returned with the error
+func getOidsByGroup(snmpConnection *g.GoSNMP, oids []string) ([]g.SnmpPDU, error) {
+	maxOids := snmpConnection.MaxOids
+	if maxOids <= 0 {
+		maxOids = g.MaxOids
+	}
+	var variables []g.SnmpPDU
+	for start := 0; start < len(oids); start += maxOids {
+		end := start + maxOids
+		if end > len(oids) {
+			end = len(oids)
+		}
+		received, err := getOids(snmpConnection, oids[start:end])
+		if err != nil {
+			return variables, err
+		}
+		variables = append(variables, received...)
+	}
+	return variables, nil
+}
+
+//getOids send a single Get request for all the oids.
+//If the agent answer that the response is too big, the request is split in 2 smaller requests.
+func getOids(snmpConnection *g.GoSNMP, oids []string) ([]g.SnmpPDU, error) {
+	log.Debugf("Get of %v OIDs in a single request", len(oids))
+	result, err := snmpConnection.Get(oids)
 	if err != nil {
-		return err
+		return nil, snmp.RequestError(snmpConnection, err)
+	}
+	if result.Error == g.TooBig && len(oids) > 1 {
+		log.Debugf("Response too big for %v OIDs, split the request", len(oids))
+		half := len(oids) / 2
+		first, err := getOids(snmpConnection, oids[:half])
+		if err != nil {
+			return nil, err
+		}
+		second, err := getOids(snmpConnection, oids[half:])
+		if err != nil {
+			return nil, err
+		}
+		return append(first, second...), nil
+	}
+	if result.Error == g.NoSuchName && snmpConnection.Version == g.Version1 {
+		//SNMPv1 agents reject the whole request if one of the OIDs is unknown, the faulty OID is removed and the request sent again
+		faulty := int(result.ErrorIndex) - 1
+		if faulty < 0 || faulty >= len(oids) {
+			return nil, errorStatus(result.Error)
+		}
+		log.Debugf("OID %v not supported by the agent, remove it from the request", oids[faulty])
+		remaining := append(append([]string{}, oids[:faulty]...), oids[faulty+1:]...)
+		if len(remaining) == 0 {
+			return nil, nil
+		}
+		return getOids(snmpConnection, remaining)
+	}
+	if result.Error != g.NoError {
+		return nil, errorStatus(result.Error)
 	}
+	return result.Variables, nil
+}
+
+//errorStatus convert the error-status of a response PDU to a human readable error
+func errorStatus(status g.SNMPError) error {
+	switch status {
+	case g.TooBig:
+		return fmt.Errorf("Get() error status received from the agent : tooBig, the response doesn't fit in a single message, reduce the max-oids value")
+	case g.NoSuchName:
+		return fmt.Errorf("Get() error status received from the agent : noSuchName, the OID isn't supported by the agent")
+	case g.BadValue:
+		return fmt.Errorf("Get() error status received from the agent : badValue, the request is malformed")
+	case g.GenErr:
+		return fmt.Errorf("Get() error status received from the agent : genErr, the agent failed to process the request")
+	default:
+		return fmt.Errorf("Get() error status received from the agent : %v", status)
+	}
+}
+
+//walkAll retrieve all the rows of the table, with GetBulk requests or GetNext requests in SNMP v1 which doesn't support GetBulk
+func walkAll(snmpConnection *g.GoSNMP, oidTable string) ([]g.SnmpPDU, error) {
+	var pdus []g.SnmpPDU
+	var err error
+	if snmpConnection.Version == g.Version1 {
+		pdus, err = snmpConnection.WalkAll(oidTable)
+	} else {
+		pdus, err = snmpConnection.BulkWalkAll(oidTable)
+	}
+	return pdus, snmp.RequestError(snmpConnection, err)
+}
 
-	switch result.Variables[0].Type {
+//isIfXElem check if the element is part of the ifXTable, not available in SNMP v1
+func isIfXElem(elem string) bool {
+	return strings.HasPrefix(InterfaceOids[elem], ifXEntryBaseOid+".")
+}
+
+//setData decode the SNMP variable received and set the value of the element
+func (i *InterfaceDetails) setData(elem string, variable g.SnmpPDU) {
+	if !reflect.ValueOf(i).Elem().FieldByName(elem).IsValid() {
+		log.Debugf("No field for elem '%v', skip...", elem)
+		return
+	}
+	switch variable.Type {
 	case g.OctetString:
-		bytes := result.Variables[0].Value.([]byte)
+		bytes := variable.Value.([]byte)
 		if reflect.ValueOf(i).Elem().FieldByName(elem).Kind() == reflect.Ptr {
 			strbytes := string(bytes)
 			reflect.ValueOf(i).Elem().FieldByName(elem).Set(reflect.ValueOf(&strbytes))
-			/* log.Debug(reflect.ValueOf(i).Elem().FieldByName(elem).Addr)
-			log.Debug(reflect.ValueOf(i).Elem().FieldByName(elem).Elem())
-			log.Debug(reflect.ValueOf(i).Elem().FieldByName(elem).Type().Elem()) */
 			log.Debugf("elem : %v string: '%v' Type: %v\n", elem, reflect.ValueOf(i).Elem().FieldByName(elem).Elem(), reflect.ValueOf(i).Elem().FieldByName(elem).Type())
 		} else {
 			reflect.ValueOf(i).Elem().FieldByName(elem).SetString(string(bytes))
@@ -129,55 +308,60 @@ func (i *InterfaceDetails) GetData(snmpConnection *g.GoSNMP, elem string) error
 	case g.NoSuchInstance:
 		log.Debugf("NoSuchInstance for elem '%v'", elem)
 	default:
-		log.Debugf("received value '%v' of type %T", result.Variables[0].Value, result.Variables[0].Value)
+		log.Debugf("received value '%v' of type %T", variable.Value, variable.Value)
 		if reflect.ValueOf(i).Elem().FieldByName(elem).Kind() == reflect.Ptr {
-			switch result.Variables[0].Value.(type) {
+			switch variable.Value.(type) {
 			case int:
 				log.Debug("int value received")
-				value := uint(result.Variables[0].Value.(int))
+				value := uint(variable.Value.(int))
 				log.Debugf("Value after conversion is '%v' of type %T", value, value)
 				reflect.ValueOf(i).Elem().FieldByName(elem).Set(reflect.ValueOf(&value))
 				log.Debugf("Value of %v is '%v' of type %v", elem, reflect.ValueOf(i).Elem().FieldByName(elem).Elem(), reflect.ValueOf(i).Elem().FieldByName(elem).Type())
 			case uint:
 				log.Debug("uint value received")
-				value := result.Variables[0].Value.(uint)
+				value := variable.Value.(uint)
+				log.Debugf("Value after conversion is '%v' of type %T", value, value)
+				reflect.ValueOf(i).Elem().FieldByName(elem).Set(reflect.ValueOf(&value))
+				log.Debugf("Value of %v is '%v' of type %v", elem, reflect.ValueOf(i).Elem().FieldByName(elem).Elem(), reflect.ValueOf(i).Elem().FieldByName(elem).Type())
+			case uint32:
+				log.Debug("uint32 value received")
+				value := uint(variable.Value.(uint32))
 				log.Debugf("Value after conversion is '%v' of type %T", value, value)
 				reflect.ValueOf(i).Elem().FieldByName(elem).Set(reflect.ValueOf(&value))
 				log.Debugf("Value of %v is '%v' of type %v", elem, reflect.ValueOf(i).Elem().FieldByName(elem).Elem(), reflect.ValueOf(i).Elem().FieldByName(elem).Type())
 			case uint64:
 				log.Debug("uint64 value received")
-				value := uint(result.Variables[0].Value.(uint64))
+				value := uint(variable.Value.(uint64))
 				log.Debugf("Value after conversion is '%v' of type %T", value, value)
 				reflect.ValueOf(i).Elem().FieldByName(elem).Set(reflect.ValueOf(&value))
 				log.Debugf("Value of %v is '%v' of type %v", elem, reflect.ValueOf(i).Elem().FieldByName(elem).Elem(), reflect.ValueOf(i).Elem().FieldByName(elem).Type())
 			default:
-				log.Debugf("Value of type %T, not handle...", result.Variables[0].Value)
+				log.Debugf("Value of type %T, not handle...", variable.Value)
 			}
 			log.Debugf("elem : %v number: %v Type: %v\n", elem, reflect.ValueOf(i).Elem().FieldByName(elem).Elem(), reflect.ValueOf(i).Elem().FieldByName(elem).Type())
 		} else {
-			value, _ := convert.ToUint(result.Variables[0].Value)
+			value, _ := convert.ToUint(variable.Value)
 			reflect.ValueOf(i).Elem().FieldByName(elem).SetUint(uint64(value))
 			log.Debugf("elem : %v number: %v Type: %v\n", elem, reflect.ValueOf(i).Elem().FieldByName(elem), reflect.ValueOf(i).Elem().FieldByName(elem).Type())
 		}
 	}
-	return nil
 }
 
 /* hrSystemUptime : 1.3.6.1.2.1.25.1.1
-sysUptime : 1.3.6.1.2.1.1.3.0
-ifLastChange : 1.3.6.1.2.1.2.2.1.9 */
+sysUptime : 1.3.6.1.2.1.1.3.0 */
 
-//GetUpTime retreive the system uptime in order to validate some data results
+//GetUpTime retreive the system uptime in order to validate some data results.
+//The sysUpTime is also kept as the reference of the ifLastChange.
 func (i *InterfaceDetails) GetUpTime(snmpConnection *g.GoSNMP) error {
 	log.Debug("=====================")
 	log.Debugf("Get Uptime")
 	oid := []string{InterfaceOids["HrSystemUptime"], InterfaceOids["SysUpTime"]}
-	result, err := snmpConnection.Get(oid)
+	variables, err := getOids(snmpConnection, oid)
 	if err != nil {
-		return fmt.Errorf("Get() UpTime err: %v", err)
+		return fmt.Errorf("Get() UpTime err: %w", err)
 	}
 
-	for _, variable := range result.Variables {
+	for _, variable := range variables {
 		if variable.Name == InterfaceOids["HrSystemUptime"] {
 			log.Debugf("Try HrSystemUptime OID")
 		} else {
@@ -187,9 +371,13 @@ func (i *InterfaceDetails) GetUpTime(snmpConnection *g.GoSNMP) error {
 		switch variable.Type {
 		case g.TimeTicks:
 			value := uint(variable.Value.(uint32))
-			i.UpTime = &value
-			log.Debugf("elem : %v number: %v Type: %T", variable.Name, *i.UpTime, i.UpTime)
-			return nil
+			if variable.Name == InterfaceOids["SysUpTime"] {
+				i.SysUpTime = &value
+			}
+			if i.UpTime == nil {
+				i.UpTime = &value
+				log.Debugf("elem : %v number: %v Type: %T", variable.Name, *i.UpTime, i.UpTime)
+			}
 		case g.NoSuchInstance:
 			log.Debugf("NoSuchInstance for elem '%v'", variable.Name)
 		case g.NoSuchObject:
@@ -0,0 +1,315 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	g "github.com/gosnmp/gosnmp"
+)
+
+//fakeAgent is an in-process SNMP agent answering the Get requests from a fixed set of values
+type fakeAgent struct {
+	t       *testing.T
+	conn    *net.UDPConn
+	version g.SnmpVersion
+	values  map[string]g.SnmpPDU
+	agentOptions
+
+	mu       sync.Mutex
+	requests [][]string
+}
+
+//agentOptions change the behaviour of the fake agent, they are set before it starts to serve
+type agentOptions struct {
+	//maxVarbinds is the number of varbinds above which the agent answer tooBig, unlimited if 0, every request rejected if negative
+	maxVarbinds int
+	//reverse send the varbinds in the reverse order of the request
+	reverse bool
+}
+
+//newFakeAgent start an agent listening on a random port of the loopback, stopped at the end of the test
+func newFakeAgent(t *testing.T, version g.SnmpVersion, values []g.SnmpPDU, opts agentOptions) *fakeAgent {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Can't start the agent : %v", err)
+	}
+	agent := &fakeAgent{t: t, conn: conn, version: version, values: make(map[string]g.SnmpPDU), agentOptions: opts}
+	for _, value := range values {
+		agent.values[value.Name] = value
+	}
+	t.Cleanup(func() { conn.Close() })
+	go agent.serve()
+	return agent
+}
+
+func (a *fakeAgent) serve() {
+	decoder := &g.GoSNMP{Version: a.version, Community: "public", Logger: g.NewLogger(nil)}
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := a.conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		request, err := decoder.SnmpDecodePacket(buffer[:n])
+		if err != nil {
+			a.t.Errorf("Can't decode the request : %v", err)
+			continue
+		}
+		response, err := a.answer(request).MarshalMsg()
+		if err != nil {
+			a.t.Errorf("Can't encode the response : %v", err)
+			continue
+		}
+		a.conn.WriteToUDP(response, addr)
+	}
+}
+
+//answer build the response of a Get request
+func (a *fakeAgent) answer(request *g.SnmpPacket) *g.SnmpPacket {
+	var oids []string
+	for _, variable := range request.Variables {
+		oids = append(oids, variable.Name)
+	}
+	a.mu.Lock()
+	a.requests = append(a.requests, oids)
+	a.mu.Unlock()
+
+	response := &g.SnmpPacket{Version: a.version, Community: "public", PDUType: g.GetResponse, RequestID: request.RequestID}
+	if a.maxVarbinds != 0 && len(oids) > a.maxVarbinds {
+		response.Error = g.TooBig
+		return response
+	}
+	for i, oid := range oids {
+		value, ok := a.values[oid]
+		if !ok && a.version == g.Version1 {
+			//The whole request is rejected, the varbinds are sent back unchanged
+			response.Error = g.NoSuchName
+			response.ErrorIndex = uint8(i + 1)
+			response.Variables = nil
+			for _, oid := range oids {
+				response.Variables = append(response.Variables, g.SnmpPDU{Name: oid, Type: g.Null})
+			}
+			return response
+		}
+		if !ok {
+			value = g.SnmpPDU{Name: oid, Type: g.NoSuchObject}
+		}
+		response.Variables = append(response.Variables, value)
+	}
+	if a.reverse {
+		for i, j := 0, len(response.Variables)-1; i < j; i, j = i+1, j-1 {
+			response.Variables[i], response.Variables[j] = response.Variables[j], response.Variables[i]
+		}
+	}
+	return response
+}
+
+//requestSizes return the number of OIDs of each request received
+func (a *fakeAgent) requestSizes() []int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var sizes []int
+	for _, oids := range a.requests {
+		sizes = append(sizes, len(oids))
+	}
+	return sizes
+}
+
+//connect open a connection to the agent
+func (a *fakeAgent) connect(t *testing.T, maxOids int) *g.GoSNMP {
+	snmpConnection := &g.GoSNMP{
+		Target:    "127.0.0.1",
+		Port:      uint16(a.conn.LocalAddr().(*net.UDPAddr).Port),
+		Community: "public",
+		Version:   a.version,
+		Timeout:   time.Second,
+		MaxOids:   maxOids,
+	}
+	err := snmpConnection.Connect()
+	if err != nil {
+		t.Fatalf("Can't connect to the agent : %v", err)
+	}
+	t.Cleanup(func() { snmpConnection.Conn.Close() })
+	return snmpConnection
+}
+
+//elemOid return the OID of the element for the interface index
+func elemOid(elem string, index int) string {
+	return InterfaceOids[elem] + "." + strconv.Itoa(index)
+}
+
+func sameSizes(got []int, expected []int) bool {
+	if len(got) != len(expected) {
+		return false
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGetDatasChunksByMaxOids(t *testing.T) {
+	elems := []string{"IfDescr", "IfSpeed", "IfAdminStatus", "IfLastChange", "IfInOctets"}
+	agent := newFakeAgent(t, g.Version2c, []g.SnmpPDU{
+		{Name: elemOid("IfDescr", 1), Type: g.OctetString, Value: "GigabitEthernet0/1"},
+		{Name: elemOid("IfSpeed", 1), Type: g.Gauge32, Value: uint32(1000000000)},
+		{Name: elemOid("IfAdminStatus", 1), Type: g.Integer, Value: UP},
+		{Name: elemOid("IfLastChange", 1), Type: g.TimeTicks, Value: uint32(4200)},
+		{Name: elemOid("IfInOctets", 1), Type: g.Counter32, Value: uint32(123456)},
+	}, agentOptions{})
+	index := 1
+	intData := &InterfaceDetails{Index: &index}
+	err := intData.GetDatas(agent.connect(t, 2), elems)
+	if err != nil {
+		t.Fatalf("GetDatas() error : %v", err)
+	}
+	if sizes := agent.requestSizes(); !sameSizes(sizes, []int{2, 2, 1}) {
+		t.Errorf("Expected requests of 2, 2 and 1 OIDs, got %v", sizes)
+	}
+	if intData.IfInOctets == nil || *intData.IfInOctets != 123456 {
+		t.Errorf("IfInOctets of the last request not decoded : %v", intData.IfInOctets)
+	}
+}
+
+func TestGetOidsSplitsTooBig(t *testing.T) {
+	var values []g.SnmpPDU
+	var oids []string
+	for index := 1; index <= 5; index++ {
+		values = append(values, g.SnmpPDU{Name: elemOid("IfInOctets", index), Type: g.Counter32, Value: uint32(index)})
+		oids = append(oids, elemOid("IfInOctets", index))
+	}
+	agent := newFakeAgent(t, g.Version2c, values, agentOptions{maxVarbinds: 2})
+	variables, err := getOidsByGroup(agent.connect(t, 60), oids)
+	if err != nil {
+		t.Fatalf("getOidsByGroup() error : %v", err)
+	}
+	if len(variables) != 5 {
+		t.Fatalf("Expected 5 variables, got %v", len(variables))
+	}
+	//5 -> tooBig, 2 -> ok, 3 -> tooBig, 1 -> ok, 2 -> ok
+	if sizes := agent.requestSizes(); !sameSizes(sizes, []int{5, 2, 3, 1, 2}) {
+		t.Errorf("Unexpected split of the requests : %v", sizes)
+	}
+	for i, variable := range variables {
+		if variable.Name != oids[i] {
+			t.Errorf("Variable %v is %v, expected %v", i, variable.Name, oids[i])
+		}
+	}
+}
+
+func TestGetOidsTooBigSingleOid(t *testing.T) {
+	agent := newFakeAgent(t, g.Version2c, nil, agentOptions{maxVarbinds: -1})
+	_, err := getOids(agent.connect(t, 60), []string{elemOid("IfDescr", 1)})
+	if err == nil {
+		t.Fatal("Expected the tooBig error of a single OID which can't be split")
+	}
+	if sizes := agent.requestSizes(); !sameSizes(sizes, []int{1}) {
+		t.Errorf("Expected a single request, got %v", sizes)
+	}
+}
+
+func TestGetDatasNoSuchObjectAndInstance(t *testing.T) {
+	agent := newFakeAgent(t, g.Version2c, []g.SnmpPDU{
+		{Name: elemOid("IfDescr", 2), Type: g.OctetString, Value: "eth1"},
+		{Name: elemOid("IfAlias", 2), Type: g.NoSuchInstance},
+		{Name: elemOid("IfHCInOctets", 2), Type: g.Counter64, Value: uint64(1) << 40},
+	}, agentOptions{})
+	index := 2
+	intData := &InterfaceDetails{Index: &index}
+	//IfName is unknown by the agent: NoSuchObject
+	err := intData.GetDatas(agent.connect(t, 60), []string{"IfDescr", "IfName", "IfAlias", "IfHCInOctets"})
+	if err != nil {
+		t.Fatalf("GetDatas() error : %v", err)
+	}
+	if intData.IfName != nil || intData.IfAlias != nil {
+		t.Errorf("The missing elements mustn't be set : IfName %v, IfAlias %v", intData.IfName, intData.IfAlias)
+	}
+	if intData.IfDescr == nil || *intData.IfDescr != "eth1" {
+		t.Errorf("IfDescr not decoded : %v", intData.IfDescr)
+	}
+	if intData.IfHCInOctets == nil || *intData.IfHCInOctets != 1<<40 {
+		t.Errorf("IfHCInOctets not decoded : %v", intData.IfHCInOctets)
+	}
+}
+
+func TestGetOidsV1NoSuchNameRetry(t *testing.T) {
+	agent := newFakeAgent(t, g.Version1, []g.SnmpPDU{
+		{Name: elemOid("IfDescr", 1), Type: g.OctetString, Value: "eth0"},
+		{Name: elemOid("IfInOctets", 1), Type: g.Counter32, Value: uint32(42)},
+	}, agentOptions{})
+	snmpConnection := agent.connect(t, 60)
+	oids := []string{elemOid("IfDescr", 1), elemOid("IfSpeed", 1), elemOid("IfInOctets", 1)}
+	variables, err := getOids(snmpConnection, oids)
+	if err != nil {
+		t.Fatalf("getOids() error : %v", err)
+	}
+	if len(variables) != 2 || variables[0].Name != oids[0] || variables[1].Name != oids[2] {
+		t.Errorf("Expected the variables of %v and %v, got %v", oids[0], oids[2], variables)
+	}
+	if sizes := agent.requestSizes(); !sameSizes(sizes, []int{3, 2}) {
+		t.Errorf("Expected a retry without the faulty OID, got requests of %v OIDs", sizes)
+	}
+
+	//No request is sent once all the OIDs have been removed
+	variables, err = getOids(snmpConnection, []string{elemOid("IfSpeed", 1)})
+	if err != nil || len(variables) != 0 {
+		t.Errorf("Expected no variable and no error, got %v, %v", variables, err)
+	}
+}
+
+func TestGetDatasDecodesByOid(t *testing.T) {
+	//The varbinds are sent in the reverse order, they must be matched by OID
+	agent := newFakeAgent(t, g.Version2c, []g.SnmpPDU{
+		{Name: elemOid("IfName", 7), Type: g.OctetString, Value: "Gi0/7"},
+		{Name: elemOid("IfAdminStatus", 7), Type: g.Integer, Value: DOWN},
+		{Name: elemOid("IfLastChange", 7), Type: g.TimeTicks, Value: uint32(300)},
+		{Name: elemOid("IfInOctets", 7), Type: g.Counter32, Value: uint32(1000)},
+		{Name: elemOid("IfHCInOctets", 7), Type: g.Counter64, Value: uint64(5000000000)},
+		{Name: elemOid("IfHighSpeed", 7), Type: g.Gauge32, Value: uint32(10000)},
+	}, agentOptions{reverse: true})
+	index := 7
+	intData := &InterfaceDetails{Index: &index}
+	err := intData.GetDatas(agent.connect(t, 60), []string{"IfName", "IfAdminStatus", "IfLastChange", "IfInOctets", "IfHCInOctets", "IfHighSpeed"})
+	if err != nil {
+		t.Fatalf("GetDatas() error : %v", err)
+	}
+	if intData.IfName == nil || *intData.IfName != "Gi0/7" {
+		t.Errorf("IfName : %v", intData.IfName)
+	}
+	expected := map[string]*uint{
+		"IfAdminStatus": intData.IfAdminStatus,
+		"IfLastChange":  intData.IfLastChange,
+		"IfInOctets":    intData.IfInOctets,
+		"IfHCInOctets":  intData.IfHCInOctets,
+		"IfHighSpeed":   intData.IfHighSpeed,
+	}
+	values := map[string]uint{"IfAdminStatus": DOWN, "IfLastChange": 300, "IfInOctets": 1000, "IfHCInOctets": 5000000000, "IfHighSpeed": 10000}
+	for elem, value := range expected {
+		if value == nil || *value != values[elem] {
+			t.Errorf("%v : expected %v, got %v", elem, values[elem], value)
+		}
+	}
+}
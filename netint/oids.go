@@ -20,37 +20,126 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
 var ifEntryBaseOid string = ".1.3.6.1.2.1.2.2.1"
 var ifXEntryBaseOid string = ".1.3.6.1.2.1.31.1.1.1"
+var dot3StatsEntryBaseOid string = ".1.3.6.1.2.1.10.7.2.1"
+
+//DeviceOids contains the OIDs used to identify the device
+var DeviceOids = map[string]string{
+	"SysObjectID":  ".1.3.6.1.2.1.1.2.0",
+	"SnmpEngineID": ".1.3.6.1.6.3.10.2.1.1.0",
+}
 
 //InterfaceOids containe all the OIDs used to grab the interface information
 var InterfaceOids = map[string]string{
-	"HrSystemUptime":        ".1.3.6.1.2.1.25.1.1.0",
-	"SysUpTime":             ".1.3.6.1.2.1.1.3.0",
-	"LocIfInCRC":            ".1.3.6.1.4.1.9.2.2.1.1.12",
-	"Dot3StatsDuplexStatus": ".1.3.6.1.2.1.10.7.2.1.19",
-	"IfIndex":               ifEntryBaseOid + ".1",
-	"IfDescr":               ifEntryBaseOid + ".2",
-	"IfSpeed":               ifEntryBaseOid + ".5",
-	"IfAdminStatus":         ifEntryBaseOid + ".7",
-	"IfOperStatus":          ifEntryBaseOid + ".8",
-	"IfInOctets":            ifEntryBaseOid + ".10",
-	"IfInUcastPkts":         ifEntryBaseOid + ".11",
-	"IfInNUcastPkts":        ifEntryBaseOid + ".12",
-	"IfInDiscards":          ifEntryBaseOid + ".13",
-	"IfInErrors":            ifEntryBaseOid + ".14",
-	"IfOutOctets":           ifEntryBaseOid + ".16",
-	"IfOutUcastPkts":        ifEntryBaseOid + ".17",
-	"IfOutNUcastPkts":       ifEntryBaseOid + ".18",
-	"IfOutDiscards":         ifEntryBaseOid + ".19",
-	"IfOutErrors":           ifEntryBaseOid + ".20",
-	"IfName":                ifXEntryBaseOid + ".1",
-	"IfHCInOctets":          ifXEntryBaseOid + ".6",
-	"IfHCInUcastPkts":       ifXEntryBaseOid + ".7",
-	"IfHCInMulticastPkts":   ifXEntryBaseOid + ".8",
-	"IfHCInBroadcastPkts":   ifXEntryBaseOid + ".9",
-	"IfHCOutOctets":         ifXEntryBaseOid + ".10",
-	"IfHCOutUcastPkts":      ifXEntryBaseOid + ".11",
-	"IfHCOutMulticastPkts":  ifXEntryBaseOid + ".12",
-	"IfHCOutBroadcastPkts":  ifXEntryBaseOid + ".13",
-	"IfHighSpeed":           ifXEntryBaseOid + ".15",
-	"IfAlias":               ifXEntryBaseOid + ".18",
+	"HrSystemUptime":                     ".1.3.6.1.2.1.25.1.1.0",
+	"SysUpTime":                          ".1.3.6.1.2.1.1.3.0",
+	"LocIfInCRC":                         ".1.3.6.1.4.1.9.2.2.1.1.12",
+	"Dot3StatsDuplexStatus":              dot3StatsEntryBaseOid + ".19",
+	"Dot3StatsAlignmentErrors":           dot3StatsEntryBaseOid + ".2",
+	"Dot3StatsFCSErrors":                 dot3StatsEntryBaseOid + ".3",
+	"Dot3StatsSingleCollisionFrames":     dot3StatsEntryBaseOid + ".4",
+	"Dot3StatsMultipleCollisionFrames":   dot3StatsEntryBaseOid + ".5",
+	"Dot3StatsDeferredTransmissions":     dot3StatsEntryBaseOid + ".7",
+	"Dot3StatsLateCollisions":            dot3StatsEntryBaseOid + ".8",
+	"Dot3StatsExcessiveCollisions":       dot3StatsEntryBaseOid + ".9",
+	"Dot3StatsInternalMacTransmitErrors": dot3StatsEntryBaseOid + ".10",
+	"Dot3StatsCarrierSenseErrors":        dot3StatsEntryBaseOid + ".11",
+	"Dot3StatsFrameTooLongs":             dot3StatsEntryBaseOid + ".13",
+	"Dot3StatsInternalMacReceiveErrors":  dot3StatsEntryBaseOid + ".16",
+	"Dot3StatsSymbolErrors":              dot3StatsEntryBaseOid + ".18",
+	"IfIndex":                            ifEntryBaseOid + ".1",
+	"IfDescr":                            ifEntryBaseOid + ".2",
+	"IfType":                             ifEntryBaseOid + ".3",
+	"IfSpeed":                            ifEntryBaseOid + ".5",
+	"IfPhysAddress":                      ifEntryBaseOid + ".6",
+	"IfAdminStatus":                      ifEntryBaseOid + ".7",
+	"IfOperStatus":                       ifEntryBaseOid + ".8",
+	"IfLastChange":                       ifEntryBaseOid + ".9",
+	"IfInOctets":                         ifEntryBaseOid + ".10",
+	"IfInUcastPkts":                      ifEntryBaseOid + ".11",
+	"IfInNUcastPkts":                     ifEntryBaseOid + ".12",
+	"IfInDiscards":                       ifEntryBaseOid + ".13",
+	"IfInErrors":                         ifEntryBaseOid + ".14",
+	"IfOutOctets":                        ifEntryBaseOid + ".16",
+	"IfOutUcastPkts":                     ifEntryBaseOid + ".17",
+	"IfOutNUcastPkts":                    ifEntryBaseOid + ".18",
+	"IfOutDiscards":                      ifEntryBaseOid + ".19",
+	"IfOutErrors":                        ifEntryBaseOid + ".20",
+	"IfName":                             ifXEntryBaseOid + ".1",
+	"IfHCInOctets":                       ifXEntryBaseOid + ".6",
+	"IfHCInUcastPkts":                    ifXEntryBaseOid + ".7",
+	"IfHCInMulticastPkts":                ifXEntryBaseOid + ".8",
+	"IfHCInBroadcastPkts":                ifXEntryBaseOid + ".9",
+	"IfHCOutOctets":                      ifXEntryBaseOid + ".10",
+	"IfHCOutUcastPkts":                   ifXEntryBaseOid + ".11",
+	"IfHCOutMulticastPkts":               ifXEntryBaseOid + ".12",
+	"IfHCOutBroadcastPkts":               ifXEntryBaseOid + ".13",
+	"IfHighSpeed":                        ifXEntryBaseOid + ".15",
+	"IfAlias":                            ifXEntryBaseOid + ".18",
+}
+
+var entPhysicalEntryBaseOid string = ".1.3.6.1.2.1.47.1.1.1.1"
+var entPhySensorEntryBaseOid string = ".1.3.6.1.2.1.99.1.1.1"
+var ciscoEntSensorValueEntryBaseOid string = ".1.3.6.1.4.1.9.9.91.1.1.1.1"
+var ciscoEntSensorThresholdEntryBaseOid string = ".1.3.6.1.4.1.9.9.91.1.2.1.1"
+var aristaEntSensorThresholdEntryBaseOid string = ".1.3.6.1.4.1.30065.3.3.1.1.1"
+var jnxDomCurrentEntryBaseOid string = ".1.3.6.1.4.1.2636.3.60.1.1.1.1"
+
+//OpticsOids contains the OIDs used to grab the measures of the optical transceivers
+var OpticsOids = map[string]string{
+	//ENTITY-MIB
+	"EntPhysicalDescr":          entPhysicalEntryBaseOid + ".2",
+	"EntPhysicalContainedIn":    entPhysicalEntryBaseOid + ".4",
+	"EntPhysicalName":           entPhysicalEntryBaseOid + ".7",
+	"EntAliasMappingIdentifier": ".1.3.6.1.2.1.47.1.3.2.1.2",
+	//ENTITY-SENSOR-MIB
+	"EntPhySensorType":      entPhySensorEntryBaseOid + ".1",
+	"EntPhySensorScale":     entPhySensorEntryBaseOid + ".2",
+	"EntPhySensorPrecision": entPhySensorEntryBaseOid + ".3",
+	"EntPhySensorValue":     entPhySensorEntryBaseOid + ".4",
+	//CISCO-ENTITY-SENSOR-MIB
+	"CiscoEntSensorType":              ciscoEntSensorValueEntryBaseOid + ".1",
+	"CiscoEntSensorScale":             ciscoEntSensorValueEntryBaseOid + ".2",
+	"CiscoEntSensorPrecision":         ciscoEntSensorValueEntryBaseOid + ".3",
+	"CiscoEntSensorValue":             ciscoEntSensorValueEntryBaseOid + ".4",
+	"CiscoEntSensorThresholdSeverity": ciscoEntSensorThresholdEntryBaseOid + ".2",
+	"CiscoEntSensorThresholdRelation": ciscoEntSensorThresholdEntryBaseOid + ".3",
+	"CiscoEntSensorThresholdValue":    ciscoEntSensorThresholdEntryBaseOid + ".4",
+	//ARISTA-ENTITY-SENSOR-MIB
+	"AristaEntSensorThresholdLowCritical":  aristaEntSensorThresholdEntryBaseOid + ".1",
+	"AristaEntSensorThresholdLowWarning":   aristaEntSensorThresholdEntryBaseOid + ".2",
+	"AristaEntSensorThresholdHighWarning":  aristaEntSensorThresholdEntryBaseOid + ".3",
+	"AristaEntSensorThresholdHighCritical": aristaEntSensorThresholdEntryBaseOid + ".4",
+	//JUNIPER-DOM-MIB, indexed by ifIndex
+	"JnxDomCurrentRxLaserPower":                           jnxDomCurrentEntryBaseOid + ".5",
+	"JnxDomCurrentTxLaserBiasCurrent":                     jnxDomCurrentEntryBaseOid + ".6",
+	"JnxDomCurrentTxLaserOutputPower":                     jnxDomCurrentEntryBaseOid + ".7",
+	"JnxDomCurrentModuleTemperature":                      jnxDomCurrentEntryBaseOid + ".8",
+	"JnxDomCurrentRxLaserPowerHighAlarmThreshold":         jnxDomCurrentEntryBaseOid + ".9",
+	"JnxDomCurrentRxLaserPowerLowAlarmThreshold":          jnxDomCurrentEntryBaseOid + ".10",
+	"JnxDomCurrentRxLaserPowerHighWarningThreshold":       jnxDomCurrentEntryBaseOid + ".11",
+	"JnxDomCurrentRxLaserPowerLowWarningThreshold":        jnxDomCurrentEntryBaseOid + ".12",
+	"JnxDomCurrentTxLaserBiasCurrentHighAlarmThreshold":   jnxDomCurrentEntryBaseOid + ".13",
+	"JnxDomCurrentTxLaserBiasCurrentLowAlarmThreshold":    jnxDomCurrentEntryBaseOid + ".14",
+	"JnxDomCurrentTxLaserBiasCurrentHighWarningThreshold": jnxDomCurrentEntryBaseOid + ".15",
+	"JnxDomCurrentTxLaserBiasCurrentLowWarningThreshold":  jnxDomCurrentEntryBaseOid + ".16",
+	"JnxDomCurrentTxLaserOutputPowerHighAlarmThreshold":   jnxDomCurrentEntryBaseOid + ".17",
+	"JnxDomCurrentTxLaserOutputPowerLowAlarmThreshold":    jnxDomCurrentEntryBaseOid + ".18",
+	"JnxDomCurrentTxLaserOutputPowerHighWarningThreshold": jnxDomCurrentEntryBaseOid + ".19",
+	"JnxDomCurrentTxLaserOutputPowerLowWarningThreshold":  jnxDomCurrentEntryBaseOid + ".20",
+	"JnxDomCurrentModuleTemperatureHighAlarmThreshold":    jnxDomCurrentEntryBaseOid + ".21",
+	"JnxDomCurrentModuleTemperatureLowAlarmThreshold":     jnxDomCurrentEntryBaseOid + ".22",
+	"JnxDomCurrentModuleTemperatureHighWarningThreshold":  jnxDomCurrentEntryBaseOid + ".23",
+	"JnxDomCurrentModuleTemperatureLowWarningThreshold":   jnxDomCurrentEntryBaseOid + ".24",
 }
+
+var dot3adAggPortEntryBaseOid string = ".1.2.840.10006.300.43.1.2.1.1"
+
+//LagOids contains the OIDs used to find the members of the link aggregations
+var LagOids = map[string]string{
+	//IEEE8023-LAG-MIB, dot3adAggPortListPorts is indexed by the ifIndex of the aggregation, dot3adAggPortTable by the ifIndex of the member
+	"Dot3adAggPortListPorts":     ".1.2.840.10006.300.43.1.1.2.1.1",
+	"Dot3adAggPortAttachedAggID": dot3adAggPortEntryBaseOid + ".13",
+}
+
+//ifStackStatusOid is the ifStackStatus of the IF-MIB ifStackTable, indexed by ifStackHigherLayer.ifStackLowerLayer
+var ifStackStatusOid string = ".1.3.6.1.2.1.31.1.2.1.3"
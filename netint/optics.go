@@ -0,0 +1,586 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go-check-network-interface/threshold"
+
+	g "github.com/gosnmp/gosnmp"
+	log "github.com/sirupsen/logrus"
+)
+
+//Sensor is a measure of an optical transceiver, with the alarm and warning thresholds of the transceiver if it provides them
+type Sensor struct {
+	Value       float64
+	LowAlarm    *float64 `json:",omitempty"`
+	LowWarning  *float64 `json:",omitempty"`
+	HighWarning *float64 `json:",omitempty"`
+	HighAlarm   *float64 `json:",omitempty"`
+}
+
+//Optics contains the measures of an optical transceiver (DOM) : the light levels in dBm,
+//the temperature in celsius, the laser bias current in mA and the supply voltage in volts
+type Optics struct {
+	RxPower     *Sensor `json:",omitempty"`
+	TxPower     *Sensor `json:",omitempty"`
+	Temperature *Sensor `json:",omitempty"`
+	BiasCurrent *Sensor `json:",omitempty"`
+	Voltage     *Sensor `json:",omitempty"`
+	//Source is the MIB providing the measures : entity, cisco or juniper
+	Source string `json:",omitempty"`
+}
+
+//OpticsLimits are the configured limits of the measures of the optical transceivers by perfdata label (rx_power...),
+//the thresholds of the transceiver are used for the measures without configured limits
+type OpticsLimits struct {
+	Limits map[string]Limits
+	//Disabled is true when the optics aren't checked, their measures aren't fetched
+	Disabled bool
+}
+
+//opticsMetric is a measure of the optical transceivers
+type opticsMetric struct {
+	//label is the perfdata label, the metric of the history and the key of the configured limits
+	label string
+	name  string
+	unit  string
+	//sensor return the address of the sensor of the metric in the optics
+	sensor func(o *Optics) **Sensor
+	//lowest is true when the lowest value of the lanes of a multi-lane transceiver is kept, the highest otherwise
+	lowest bool
+}
+
+var opticsMetrics = []opticsMetric{
+	{"rx_power", "Rx Power", "dBm", func(o *Optics) **Sensor { return &o.RxPower }, true},
+	{"tx_power", "Tx Power", "dBm", func(o *Optics) **Sensor { return &o.TxPower }, true},
+	{"optics_temperature", "Temperature", "C", func(o *Optics) **Sensor { return &o.Temperature }, false},
+	{"bias_current", "Bias Current", "mA", func(o *Optics) **Sensor { return &o.BiasCurrent }, false},
+	{"optics_voltage", "Voltage", "V", func(o *Optics) **Sensor { return &o.Voltage }, true},
+}
+
+//OpticsMetrics return the labels of the measures of the optical transceivers
+func OpticsMetrics() []string {
+	var labels []string
+	for _, metric := range opticsMetrics {
+		labels = append(labels, metric.label)
+	}
+	return labels
+}
+
+//Measure return the sensor of the metric and the limits tested against its value : the configured limits of the metric,
+//or the thresholds of the transceiver if none are configured. The sensor is nil if the metric isn't measured.
+func (o *Optics) Measure(label string, configured map[string]Limits) (*Sensor, Limits) {
+	for _, metric := range opticsMetrics {
+		if metric.label != label {
+			continue
+		}
+		if o == nil || *metric.sensor(o) == nil {
+			return nil, Limits{Unit: metric.unit, Disabled: true}
+		}
+		sensor := *metric.sensor(o)
+		if limits, ok := configured[label]; ok {
+			return sensor, limits
+		}
+		return sensor, sensor.limits(metric.unit)
+	}
+	return nil, Limits{Disabled: true}
+}
+
+//limits return the limits built from the thresholds of the transceiver, disabled if it doesn't provide any
+func (s *Sensor) limits(unit string) Limits {
+	if s.LowAlarm == nil && s.LowWarning == nil && s.HighWarning == nil && s.HighAlarm == nil {
+		return Limits{Unit: unit, Disabled: true}
+	}
+	return Limits{
+		Warn: Limit{Range: sensorRange(s.LowWarning, s.HighWarning)},
+		Crit: Limit{Range: sensorRange(s.LowAlarm, s.HighAlarm)},
+		Unit: unit,
+	}
+}
+
+//sensorRange return the range between the low and the high thresholds, unbounded on the side of a missing threshold
+func sensorRange(low *float64, high *float64) threshold.Range {
+	r := threshold.Range{Start: math.Inf(-1), End: math.Inf(1)}
+	if low != nil {
+		r.Start = *low
+	}
+	if high != nil {
+		r.End = *high
+	}
+	return r
+}
+
+//Transceiver add the perfdata of the measures of the optical transceiver and make the test with the configured limits
+//or the thresholds of the transceiver to update the check
+func Transceiver(intNewData *InterfaceDetails, chk *Check, limits OpticsLimits, eval *Evaluation) {
+	log.Debug("===== Optics =====")
+	if limits.Disabled {
+		log.Debug("Optics check disabled, skip...")
+		return
+	}
+	if intNewData.Optics == nil {
+		log.Debug("No measure of an optical transceiver available, skip...")
+		return
+	}
+	for _, metric := range opticsMetrics {
+		sensor, metricLimits := intNewData.Optics.Measure(metric.label, limits.Limits)
+		if sensor == nil {
+			continue
+		}
+		warn, crit := perfThresholds(metric.unit, metricLimits)
+		chk.AddPerfData(metric.label, strconv.FormatFloat(sensor.Value, 'f', 2, 64), metric.unit, warn, crit, "", "")
+
+		value := eval.Value(metric.label, &sensor.Value)
+		limit, critical, held, ok := eval.test(metric.label, value, metricLimits)
+		if !ok {
+			continue
+		}
+		chk.AddShort(fmt.Sprintf("%v %v%v : %v (%v)", limit.qualify(*value, critical), metric.name, eval.Label(),
+			fmtStatus(fmt.Sprintf("%.2f %v", *value, metric.unit), critical), limit.describe(*value, " "+metric.unit, held)),
+			true)
+		addStatus(chk, critical)
+	}
+}
+
+//Vendors identified by the prefix of their sysObjectID
+const (
+	juniperObjectID = ".1.3.6.1.4.1.2636."
+	ciscoObjectID   = ".1.3.6.1.4.1.9."
+	aristaObjectID  = ".1.3.6.1.4.1.30065."
+)
+
+//FetchOptics retrieve the measures of the optical transceivers of the interfaces.
+//The Juniper devices are polled with the JUNIPER-DOM-MIB, the Cisco devices with the CISCO-ENTITY-SENSOR-MIB
+//and the others with the ENTITY-SENSOR-MIB, with the thresholds of the ARISTA-ENTITY-SENSOR-MIB on Arista.
+//The sensors of the entity MIBs are mapped to the interfaces with the entAliasMappingTable.
+func FetchOptics(snmpConnection *g.GoSNMP, identity *DeviceIdentity, interfaces []*InterfaceDetails) error {
+	log.Debug("=====================")
+	log.Debugf("FetchOptics of %v interface(s)", len(interfaces))
+	var objectID string
+	if identity != nil {
+		objectID = "." + strings.TrimPrefix(identity.SysObjectID, ".")
+	}
+	if strings.HasPrefix(objectID, juniperObjectID) {
+		return fetchJuniperOptics(snmpConnection, interfaces)
+	}
+	sensors := ciscoSensors
+	if !strings.HasPrefix(objectID, ciscoObjectID) {
+		sensors = entitySensors
+		sensors.arista = strings.HasPrefix(objectID, aristaObjectID)
+	}
+	found, err := fetchEntityOptics(snmpConnection, sensors, interfaces)
+	if err != nil || found || !strings.HasPrefix(objectID, ciscoObjectID) {
+		return err
+	}
+	//Some Cisco platforms (NX-OS) only implement the ENTITY-SENSOR-MIB
+	_, err = fetchEntityOptics(snmpConnection, entitySensors, interfaces)
+	return err
+}
+
+//fetchJuniperOptics retrieve the measures of the jnxDomCurrentTable, indexed by ifIndex
+func fetchJuniperOptics(snmpConnection *g.GoSNMP, interfaces []*InterfaceDetails) error {
+	//The light levels are in 0.01 dBm, the bias current in 0.001 mA and the temperature in celsius
+	measures := []struct {
+		elem    string
+		divisor float64
+		sensor  func(o *Optics) **Sensor
+	}{
+		{"JnxDomCurrentRxLaserPower", 100, func(o *Optics) **Sensor { return &o.RxPower }},
+		{"JnxDomCurrentTxLaserOutputPower", 100, func(o *Optics) **Sensor { return &o.TxPower }},
+		{"JnxDomCurrentTxLaserBiasCurrent", 1000, func(o *Optics) **Sensor { return &o.BiasCurrent }},
+		{"JnxDomCurrentModuleTemperature", 1, func(o *Optics) **Sensor { return &o.Temperature }},
+	}
+	for _, intData := range interfaces {
+		values := make(map[string]float64)
+		var oids []string
+		oidToElem := make(map[string]string)
+		for _, measure := range measures {
+			for _, suffix := range []string{"", "HighAlarmThreshold", "LowAlarmThreshold", "HighWarningThreshold", "LowWarningThreshold"} {
+				oid := OpticsOids[measure.elem+suffix] + "." + strconv.Itoa(*intData.Index)
+				oids = append(oids, oid)
+				oidToElem[oid] = measure.elem + suffix
+			}
+		}
+		variables, err := getOidsByGroup(snmpConnection, oids)
+		if err != nil {
+			return err
+		}
+		for _, variable := range variables {
+			if value, ok := pduInt(variable); ok {
+				values[oidToElem["."+strings.TrimPrefix(variable.Name, ".")]] = float64(value)
+			}
+		}
+
+		optics := &Optics{Source: "juniper"}
+		for _, measure := range measures {
+			value, ok := values[measure.elem]
+			if !ok {
+				continue
+			}
+			limit := func(suffix string) *float64 {
+				if t, ok := values[measure.elem+suffix]; ok {
+					t /= measure.divisor
+					return &t
+				}
+				return nil
+			}
+			*measure.sensor(optics) = &Sensor{
+				Value:       value / measure.divisor,
+				LowAlarm:    limit("LowAlarmThreshold"),
+				LowWarning:  limit("LowWarningThreshold"),
+				HighWarning: limit("HighWarningThreshold"),
+				HighAlarm:   limit("HighAlarmThreshold"),
+			}
+		}
+		if optics.RxPower != nil || optics.TxPower != nil {
+			log.Debugf("Optics of the interface %v : %+v", *intData.Index, optics)
+			intData.Optics = optics
+		}
+	}
+	return nil
+}
+
+//entitySensorTables are the columns of a sensor table of the ENTITY-SENSOR-MIB or of one of its vendor variants
+type entitySensorTables struct {
+	source                              string
+	sensorType, scale, precision, value string
+	//cisco is true to read the thresholds of the Cisco entSensorThresholdTable
+	cisco bool
+	//arista is true to read the thresholds of the aristaEntSensorThresholdTable
+	arista bool
+}
+
+var entitySensors = entitySensorTables{
+	source:     "entity",
+	sensorType: "EntPhySensorType",
+	scale:      "EntPhySensorScale",
+	precision:  "EntPhySensorPrecision",
+	value:      "EntPhySensorValue",
+}
+
+var ciscoSensors = entitySensorTables{
+	source:     "cisco",
+	sensorType: "CiscoEntSensorType",
+	scale:      "CiscoEntSensorScale",
+	precision:  "CiscoEntSensorPrecision",
+	value:      "CiscoEntSensorValue",
+	cisco:      true,
+}
+
+//Types of the sensors (EntitySensorDataType and SensorDataType of the Cisco MIB)
+const (
+	sensorVoltsDC  = 4
+	sensorAmperes  = 5
+	sensorWatts    = 6
+	sensorCelsius  = 8
+	sensorDBm      = 14
+	dBmFloor       = -40.0
+	maxEntityDepth = 16
+)
+
+var (
+	rxSensorRegex = regexp.MustCompile(`(?i)\b(rx|receive)`)
+	txSensorRegex = regexp.MustCompile(`(?i)\b(tx|transmit)`)
+)
+
+//fetchEntityOptics retrieve the sensors of the transceivers from the sensor tables and map them to the interfaces.
+//It returns true if at least one measure was found.
+func fetchEntityOptics(snmpConnection *g.GoSNMP, tables entitySensorTables, interfaces []*InterfaceDetails) (bool, error) {
+	elems := []string{tables.sensorType, tables.scale, tables.precision, tables.value}
+	if tables.cisco {
+		elems = append(elems, "CiscoEntSensorThresholdSeverity", "CiscoEntSensorThresholdRelation", "CiscoEntSensorThresholdValue")
+	}
+	if tables.arista {
+		elems = append(elems, "AristaEntSensorThresholdLowCritical", "AristaEntSensorThresholdLowWarning",
+			"AristaEntSensorThresholdHighWarning", "AristaEntSensorThresholdHighCritical")
+	}
+	sensors, err := walkColumns(snmpConnection, elems...)
+	if err != nil || len(sensors[tables.value]) == 0 {
+		log.Debugf("No sensor found in the %v sensor table", tables.source)
+		return false, err
+	}
+	entities, err := walkColumns(snmpConnection, "EntPhysicalName", "EntPhysicalDescr", "EntPhysicalContainedIn", "EntAliasMappingIdentifier")
+	if err != nil {
+		return false, err
+	}
+	mapping := newEntityMapping(entities, interfaces)
+
+	byIndex := make(map[int]*InterfaceDetails)
+	for _, intData := range interfaces {
+		byIndex[*intData.Index] = intData
+	}
+	var found bool
+	for index, valuePdu := range sensors[tables.value] {
+		sensorType, _ := pduInt(sensors[tables.sensorType][index])
+		metric, ok := sensorMetric(sensorType, pduString(entities["EntPhysicalName"][index])+" "+pduString(entities["EntPhysicalDescr"][index]))
+		if !ok {
+			continue
+		}
+		intData, ok := byIndex[mapping.ifIndex(index)]
+		if !ok {
+			continue
+		}
+		value, ok := pduInt(valuePdu)
+		if !ok {
+			continue
+		}
+		scale, _ := pduInt(sensors[tables.scale][index])
+		precision, _ := pduInt(sensors[tables.precision][index])
+		convert := func(raw int64) float64 {
+			return sensorUnit(sensorType, sensorValue(raw, scale, precision))
+		}
+		sensor := &Sensor{Value: convert(value)}
+		if tables.cisco {
+			ciscoThresholds(sensor, sensors, index, convert)
+		}
+		if tables.arista {
+			aristaThresholds(sensor, sensors, index, convert)
+		}
+		log.Debugf("Sensor %v of the interface %v : %v %+v", index, *intData.Index, metric.label, sensor)
+
+		if intData.Optics == nil {
+			intData.Optics = &Optics{Source: tables.source}
+		}
+		current := metric.sensor(intData.Optics)
+		if *current == nil || (metric.lowest && sensor.Value < (*current).Value) || (!metric.lowest && sensor.Value > (*current).Value) {
+			*current = sensor
+		}
+		found = true
+	}
+	return found, nil
+}
+
+//sensorMetric return the metric measured by a sensor from its type and its name
+func sensorMetric(sensorType int64, name string) (opticsMetric, bool) {
+	var label string
+	switch sensorType {
+	case sensorDBm, sensorWatts:
+		switch {
+		case rxSensorRegex.MatchString(name):
+			label = "rx_power"
+		case txSensorRegex.MatchString(name):
+			label = "tx_power"
+		}
+	case sensorCelsius:
+		label = "optics_temperature"
+	case sensorAmperes:
+		label = "bias_current"
+	case sensorVoltsDC:
+		label = "optics_voltage"
+	}
+	for _, metric := range opticsMetrics {
+		if metric.label == label {
+			return metric, true
+		}
+	}
+	return opticsMetric{}, false
+}
+
+//sensorValue apply the scale (SI prefix, 9 is units) and the precision (number of decimals) to the raw value of a sensor
+func sensorValue(value int64, scale int64, precision int64) float64 {
+	return float64(value) * math.Pow(10, float64(3*(scale-9))) / math.Pow(10, float64(precision))
+}
+
+//sensorUnit convert the value of a sensor to the unit of its metric : the watts in dBm and the amperes in mA
+func sensorUnit(sensorType int64, value float64) float64 {
+	switch sensorType {
+	case sensorWatts:
+		if value <= 0 {
+			return dBmFloor
+		}
+		return math.Max(10*math.Log10(value*1000), dBmFloor)
+	case sensorAmperes:
+		return value * 1000
+	}
+	return value
+}
+
+//ciscoThresholds set the thresholds of the sensor from the entSensorThresholdTable indexed by sensor and threshold.
+//The minor thresholds are the warnings, the major and critical ones are the alarms.
+func ciscoThresholds(sensor *Sensor, sensors map[string]map[string]g.SnmpPDU, index string, convert func(int64) float64) {
+	for thresholdIndex, valuePdu := range sensors["CiscoEntSensorThresholdValue"] {
+		if !strings.HasPrefix(thresholdIndex, index+".") {
+			continue
+		}
+		raw, ok := pduInt(valuePdu)
+		if !ok {
+			continue
+		}
+		value := convert(raw)
+		//1-other, 10-minor, 20-major, 30-critical
+		severity, _ := pduInt(sensors["CiscoEntSensorThresholdSeverity"][thresholdIndex])
+		//1-lessThan, 2-lessOrEqual, 3-greaterThan, 4-greaterOrEqual, 5-equalTo, 6-notEqualTo
+		relation, _ := pduInt(sensors["CiscoEntSensorThresholdRelation"][thresholdIndex])
+		var target **float64
+		switch {
+		case severity == 10 && (relation == 1 || relation == 2):
+			target = &sensor.LowWarning
+		case severity == 10 && (relation == 3 || relation == 4):
+			target = &sensor.HighWarning
+		case severity >= 20 && (relation == 1 || relation == 2):
+			target = &sensor.LowAlarm
+		case severity >= 20 && (relation == 3 || relation == 4):
+			target = &sensor.HighAlarm
+		default:
+			continue
+		}
+		if *target == nil {
+			*target = &value
+		}
+	}
+}
+
+//aristaThresholds set the thresholds of the sensor from the aristaEntSensorThresholdTable, indexed by sensor
+func aristaThresholds(sensor *Sensor, sensors map[string]map[string]g.SnmpPDU, index string, convert func(int64) float64) {
+	for elem, target := range map[string]**float64{
+		"AristaEntSensorThresholdLowCritical":  &sensor.LowAlarm,
+		"AristaEntSensorThresholdLowWarning":   &sensor.LowWarning,
+		"AristaEntSensorThresholdHighWarning":  &sensor.HighWarning,
+		"AristaEntSensorThresholdHighCritical": &sensor.HighAlarm,
+	} {
+		if raw, ok := pduInt(sensors[elem][index]); ok {
+			value := convert(raw)
+			*target = &value
+		}
+	}
+}
+
+//entityMapping map the physical entities to the interfaces
+type entityMapping struct {
+	//aliases are the ifIndex of the entities of the entAliasMappingTable
+	aliases map[string]int
+	//parents are the containers of the entities
+	parents map[string]string
+	//names are the names and descriptions of the entities
+	names      map[string]string
+	interfaces []*InterfaceDetails
+}
+
+//newEntityMapping build the mapping from the columns of the entPhysicalTable and the entAliasMappingTable
+func newEntityMapping(entities map[string]map[string]g.SnmpPDU, interfaces []*InterfaceDetails) *entityMapping {
+	m := &entityMapping{aliases: make(map[string]int), parents: make(map[string]string), names: make(map[string]string), interfaces: interfaces}
+	//The index of the entAliasMappingTable is entPhysicalIndex.entAliasLogicalIndexOrZero, the value is the OID of the ifIndex
+	for index, pdu := range entities["EntAliasMappingIdentifier"] {
+		oid, ok := pdu.Value.(string)
+		if !ok || !strings.HasPrefix("."+strings.TrimPrefix(oid, "."), InterfaceOids["IfIndex"]+".") {
+			continue
+		}
+		ifIndex, err := strconv.Atoi(oid[strings.LastIndex(oid, ".")+1:])
+		if err != nil {
+			continue
+		}
+		m.aliases[strings.SplitN(index, ".", 2)[0]] = ifIndex
+	}
+	for index, pdu := range entities["EntPhysicalContainedIn"] {
+		if parent, ok := pduInt(pdu); ok {
+			m.parents[index] = strconv.FormatInt(parent, 10)
+		}
+	}
+	for index, pdu := range entities["EntPhysicalName"] {
+		m.names[index] = pduString(pdu) + " " + pduString(entities["EntPhysicalDescr"][index])
+	}
+	return m
+}
+
+//ifIndex return the ifIndex of the interface of the sensor, from the first container of the sensor mapped to an interface
+//or from the name of an interface found in the name of the sensor, -1 if not found
+func (m *entityMapping) ifIndex(entity string) int {
+	for current, depth := entity, 0; current != "" && current != "0" && depth < maxEntityDepth; current, depth = m.parents[current], depth+1 {
+		if ifIndex, ok := m.aliases[current]; ok {
+			return ifIndex
+		}
+	}
+	for _, intData := range m.interfaces {
+		for _, name := range []*string{intData.IfName, intData.IfDescr} {
+			if name == nil || *name == "" {
+				continue
+			}
+			if containsName(m.names[entity], *name) {
+				return *intData.Index
+			}
+		}
+	}
+	return -1
+}
+
+//containsName check if the name of the interface is found in the text as a whole word, ex: "Te1/1" in "Te1/1 Rx Power"
+//but not in "Te1/10 Rx Power". The names shorter than 3 characters are ignored as too ambiguous.
+func containsName(text string, name string) bool {
+	if len(name) < 3 {
+		return false
+	}
+	isPart := func(r byte) bool {
+		return r == '/' || r == '.' || r == ':' || r == '-' || r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	}
+	for start := 0; start <= len(text)-len(name); {
+		i := strings.Index(text[start:], name)
+		if i < 0 {
+			return false
+		}
+		begin, end := start+i, start+i+len(name)
+		if (begin == 0 || !isPart(text[begin-1])) && (end == len(text) || !isPart(text[end])) {
+			return true
+		}
+		start = begin + 1
+	}
+	return false
+}
+
+//walkColumns walk the columns of the elements of the OpticsOids map and return their values by element then by index
+func walkColumns(snmpConnection *g.GoSNMP, elems ...string) (map[string]map[string]g.SnmpPDU, error) {
+	columns := make(map[string]map[string]g.SnmpPDU)
+	for _, elem := range elems {
+		oid := OpticsOids[elem]
+		pdus, err := walkAll(snmpConnection, oid)
+		if err != nil {
+			return nil, err
+		}
+		columns[elem] = make(map[string]g.SnmpPDU)
+		for _, pdu := range pdus {
+			name := "." + strings.TrimPrefix(pdu.Name, ".")
+			if strings.HasPrefix(name, oid+".") {
+				columns[elem][strings.TrimPrefix(name, oid+".")] = pdu
+			}
+		}
+	}
+	return columns, nil
+}
+
+//pduInt return the signed integer value of the variable, false if the variable has no integer value
+func pduInt(pdu g.SnmpPDU) (int64, bool) {
+	switch pdu.Type {
+	case g.Integer, g.Counter32, g.Gauge32, g.Counter64, g.TimeTicks, g.Uinteger32:
+		return g.ToBigInt(pdu.Value).Int64(), true
+	}
+	return 0, false
+}
+
+//pduString return the value of a string variable, empty if the variable isn't a string
+func pduString(pdu g.SnmpPDU) string {
+	if value, ok := pdu.Value.([]byte); ok {
+		return string(value)
+	}
+	return ""
+}
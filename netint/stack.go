@@ -0,0 +1,181 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"strconv"
+	"strings"
+
+	g "github.com/gosnmp/gosnmp"
+	log "github.com/sirupsen/logrus"
+)
+
+//Types of the virtual interfaces whose speed is nominal (tunnel, l2vlan, l3ipvlan)
+var virtualIfTypes = map[uint]bool{131: true, 135: true, 136: true}
+
+//maxStackDepth is the maximum number of layers followed below an interface, to stop on a loop of the ifStackTable
+const maxStackDepth = 8
+
+//StackLayer is an interface below a logical interface (subinterface, vlan, tunnel...) in the ifStackTable
+type StackLayer struct {
+	Index      int
+	Name       string
+	IfType     *uint `json:",omitempty"`
+	OperStatus *uint `json:",omitempty"`
+	//Speed is the speed reported by the interface in bps
+	Speed uint
+}
+
+//Parent return the physical parent of the interface, the lowest layer of its stack, nil if it has none
+func (i *InterfaceDetails) Parent() *StackLayer {
+	if len(i.Stack) == 0 {
+		return nil
+	}
+	return i.Stack[len(i.Stack)-1]
+}
+
+//DownLayer return the first layer of the stack which isn't UP, the cause of an interface down, nil if none
+func (i *InterfaceDetails) DownLayer() *StackLayer {
+	for _, layer := range i.Stack {
+		if layer.OperStatus != nil && *layer.OperStatus != UP {
+			return layer
+		}
+	}
+	return nil
+}
+
+//StackPath describe the stack of the interface from itself to its physical parent, ex: "Gi0/0.100 > Gi0/0"
+func (i *InterfaceDetails) StackPath() string {
+	names := []string{strconv.Itoa(*i.Index)}
+	if i.IfName != nil && *i.IfName != "" {
+		names[0] = *i.IfName
+	} else if i.IfDescr != nil && *i.IfDescr != "" {
+		names[0] = *i.IfDescr
+	}
+	for _, layer := range i.Stack {
+		names = append(names, layer.Name)
+	}
+	return strings.Join(names, " > ")
+}
+
+//isVirtual check if the interface is a virtual interface without physical parent, its speed is nominal
+func (i *InterfaceDetails) isVirtual() bool {
+	return len(i.Stack) == 0 && i.IfType != nil && virtualIfTypes[*i.IfType]
+}
+
+//ifStack gives the active lower layers of the interfaces from the ifStackTable,
+//the table is walked entirely when complete or interface by interface otherwise
+type ifStack struct {
+	snmpConnection *g.GoSNMP
+	lowers         map[int][]int
+	complete       bool
+}
+
+//newIfStack create the ifStack, the whole ifStackTable is walked if complete
+func newIfStack(snmpConnection *g.GoSNMP, complete bool) (*ifStack, error) {
+	s := &ifStack{snmpConnection: snmpConnection, lowers: make(map[int][]int)}
+	if !complete {
+		return s, nil
+	}
+	err := s.walk(ifStackStatusOid)
+	s.complete = err == nil
+	return s, err
+}
+
+//lowerLayers return the ifIndex of the active interfaces directly below the interface
+func (s *ifStack) lowerLayers(index int) ([]int, error) {
+	if lowers, ok := s.lowers[index]; ok || s.complete {
+		return lowers, nil
+	}
+	err := s.walk(ifStackStatusOid + "." + strconv.Itoa(index))
+	if _, ok := s.lowers[index]; !ok {
+		s.lowers[index] = nil
+	}
+	return s.lowers[index], err
+}
+
+//walk read the rows of the ifStackTable below the OID, indexed by ifStackHigherLayer.ifStackLowerLayer
+func (s *ifStack) walk(oid string) error {
+	pdus, err := walkAll(s.snmpConnection, oid)
+	if err != nil {
+		return err
+	}
+	for _, pdu := range pdus {
+		layers := strings.Split(strings.TrimPrefix("."+strings.TrimPrefix(pdu.Name, "."), ifStackStatusOid+"."), ".")
+		if len(layers) != 2 {
+			continue
+		}
+		higher, errHigher := strconv.Atoi(layers[0])
+		lower, errLower := strconv.Atoi(layers[1])
+		//1-active, the layer 0 means that there is no interface above or below
+		if status, ok := pduInt(pdu); errHigher != nil || errLower != nil || !ok || status != 1 || higher == 0 || lower == 0 {
+			continue
+		}
+		s.lowers[higher] = append(s.lowers[higher], lower)
+	}
+	return nil
+}
+
+//FetchStacks retrieve the layers below the interfaces in the ifStackTable down to their physical parent.
+//The stack of an interface is followed while it has a single lower layer, and stops on an aggregation.
+func FetchStacks(snmpConnection *g.GoSNMP, interfaces []*InterfaceDetails) error {
+	log.Debug("=====================")
+	log.Debugf("FetchStacks of %v interface(s)", len(interfaces))
+	//The whole table is walked at once when several interfaces are checked
+	stack, err := newIfStack(snmpConnection, len(interfaces) > 1)
+	if err != nil {
+		return err
+	}
+	elems := []string{"IfName", "IfDescr", "IfType", "IfOperStatus", "IfSpeed", "IfHighSpeed"}
+	if snmpConnection.Version == g.Version1 {
+		elems = []string{"IfDescr", "IfType", "IfOperStatus", "IfSpeed"}
+	}
+	for _, intData := range interfaces {
+		intData.Stack = nil
+		visited := map[int]bool{*intData.Index: true}
+		current, currentType := *intData.Index, intData.IfType
+		for depth := 0; depth < maxStackDepth; depth++ {
+			if currentType != nil && *currentType == ifTypeLag {
+				break
+			}
+			lowers, err := stack.lowerLayers(current)
+			if err != nil {
+				return err
+			}
+			if len(lowers) != 1 || visited[lowers[0]] {
+				break
+			}
+			current = lowers[0]
+			visited[current] = true
+			index := current
+			details := &InterfaceDetails{Index: &index}
+			err = details.GetDatas(snmpConnection, elems)
+			if err != nil {
+				return err
+			}
+			intData.Stack = append(intData.Stack, &StackLayer{Index: current, Name: memberName(details), IfType: details.IfType,
+				OperStatus: details.IfOperStatus, Speed: reportedSpeed(details)})
+			currentType = details.IfType
+		}
+		if len(intData.Stack) > 0 {
+			log.Debugf("Stack of the interface %v : %v", *intData.Index, intData.StackPath())
+		}
+	}
+	return nil
+}
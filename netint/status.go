@@ -0,0 +1,88 @@
+package netint
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"strings"
+
+	sknchk "github.com/pandaoc-io/go-shinken-check"
+)
+
+//StatusPolicy defines the state of the check for each admin and oper status of the interface other than UP
+type StatusPolicy struct {
+	//AdminDown is the state of an interface administratively down
+	AdminDown sknchk.Status
+	//Oper is the state of an interface administratively up for each of its oper status (DOWN, DORMANT...), Critical if missing
+	Oper map[uint]sknchk.Status
+	//ExpectDown reverse the check, the interface is expected to be down and is Critical when UP
+	ExpectDown bool
+}
+
+//AdminDownState return the state of an interface administratively down
+func (p *StatusPolicy) AdminDownState() sknchk.Status {
+	if p.ExpectDown {
+		return sknchk.RcOk
+	}
+	return p.AdminDown
+}
+
+//OperState return the state of an interface administratively up with the oper status given in argument
+func (p *StatusPolicy) OperState(oper uint) sknchk.Status {
+	switch {
+	case oper == UP && p.ExpectDown:
+		return sknchk.RcCritical
+	case oper == UP, p.ExpectDown:
+		return sknchk.RcOk
+	}
+	if rc, ok := p.Oper[oper]; ok {
+		return rc
+	}
+	return sknchk.RcCritical
+}
+
+//ParseState convert the name of a state (ok|warning|critical|unknown) to its status
+func ParseState(state string) (sknchk.Status, error) {
+	switch strings.ToLower(state) {
+	case "ok":
+		return sknchk.RcOk, nil
+	case "warning":
+		return sknchk.RcWarning, nil
+	case "critical":
+		return sknchk.RcCritical, nil
+	case "unknown":
+		return sknchk.RcUnknwon, nil
+	default:
+		return sknchk.RcUnknwon, fmt.Errorf("Unknown state %v, must be ok, warning, critical or unknown", state)
+	}
+}
+
+//FmtState format the string in the output with the color of the status
+func FmtState(rc sknchk.Status, str string) string {
+	switch rc {
+	case sknchk.RcOk:
+		return sknchk.FmtOk(str)
+	case sknchk.RcWarning:
+		return sknchk.FmtWarning(str)
+	case sknchk.RcCritical:
+		return sknchk.FmtCritical(str)
+	default:
+		return str
+	}
+}
@@ -0,0 +1,198 @@
+package profile
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"go-check-network-interface/netint"
+
+	"gopkg.in/yaml.v3"
+)
+
+//Keys are the flags which can be set by a profile
+var Keys = []string{
+	"bandwidth-warning", "bandwidth-critical",
+	"bandwidth-in-warning", "bandwidth-in-critical",
+	"bandwidth-out-warning", "bandwidth-out-critical",
+	"error-warning", "error-critical",
+	"error-in-warning", "error-in-critical",
+	"error-out-warning", "error-out-critical",
+	"discard-warning", "discard-critical",
+	"discard-in-warning", "discard-in-critical",
+	"discard-out-warning", "discard-out-critical",
+	"dot3-error-warning", "dot3-error-critical",
+	"collision-warning", "collision-critical",
+	"duplex-mismatch",
+	"rx-power-warning", "rx-power-critical",
+	"tx-power-warning", "tx-power-critical",
+	"optics-temperature-warning", "optics-temperature-critical",
+	"lag-capacity-warning", "lag-capacity-critical",
+	"lag-unbalance-warning", "lag-unbalance-critical",
+	"flap-warning", "flap-critical",
+	"grace-period",
+	"speed-override",
+	"checks",
+	"admin-down", "oper-down", "testing", "oper-unknown", "dormant", "not-present", "lower-layer-down",
+	"expect-down",
+}
+
+//Settings are the values of the flags by flag name (bandwidth-warning: 80%, speed-override: 10M/1M...)
+type Settings map[string]string
+
+//Profile is a set of settings applied to the interfaces matching its criteria
+type Profile struct {
+	Name     string   `yaml:"name"`
+	Match    Match    `yaml:"match"`
+	Settings Settings `yaml:"settings"`
+}
+
+//Match are the criteria of selection of the interfaces of a profile, all the given criteria must match.
+//A profile without criteria match all the interfaces.
+type Match struct {
+	//Name is a regular expression on the ifName or the ifDescr
+	Name string `yaml:"name"`
+	//Alias is a regular expression on the ifAlias
+	Alias string `yaml:"alias"`
+	//Type is the list of the accepted ifType (6 for ethernetCsmacd, 161 for ieee8023adLag...)
+	Type []uint `yaml:"type"`
+
+	name, alias *regexp.Regexp
+}
+
+//Config is the content of the profiles file, the first profile matching an interface is applied
+type Config struct {
+	Profiles []*Profile `yaml:"profiles"`
+}
+
+//Load read and check the profiles file
+func Load(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading the profiles file : %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	config := &Config{}
+	err = decoder.Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("Error while parsing the profiles file %v : %w", path, err)
+	}
+
+	names := make(map[string]bool)
+	for i, p := range config.Profiles {
+		if p.Name == "" {
+			return nil, fmt.Errorf("The profile number %v of the file %v has no name", i+1, path)
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("The profile %v is defined several times in the file %v", p.Name, path)
+		}
+		names[p.Name] = true
+		for key := range p.Settings {
+			if !isKey(key) {
+				return nil, fmt.Errorf("Unknown setting %v in the profile %v, must be one of %v", key, p.Name, strings.Join(Keys, ", "))
+			}
+		}
+		if p.Match.Name != "" {
+			if p.Match.name, err = regexp.Compile(p.Match.Name); err != nil {
+				return nil, fmt.Errorf("Invalid name regex %v in the profile %v : %w", p.Match.Name, p.Name, err)
+			}
+		}
+		if p.Match.Alias != "" {
+			if p.Match.alias, err = regexp.Compile(p.Match.Alias); err != nil {
+				return nil, fmt.Errorf("Invalid alias regex %v in the profile %v : %w", p.Match.Alias, p.Name, err)
+			}
+		}
+	}
+	return config, nil
+}
+
+//Find return the first profile matching the interface, nil if none
+func (c *Config) Find(intData *netint.InterfaceDetails) *Profile {
+	if c == nil {
+		return nil
+	}
+	for _, p := range c.Profiles {
+		if p.Match.match(intData) {
+			return p
+		}
+	}
+	return nil
+}
+
+func (m *Match) match(intData *netint.InterfaceDetails) bool {
+	if m.name != nil && !matchString(m.name, intData.IfName) && !matchString(m.name, intData.IfDescr) {
+		return false
+	}
+	if m.alias != nil && !matchString(m.alias, intData.IfAlias) {
+		return false
+	}
+	if len(m.Type) > 0 {
+		if intData.IfType == nil {
+			return false
+		}
+		for _, ifType := range m.Type {
+			if ifType == *intData.IfType {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchString(re *regexp.Regexp, value *string) bool {
+	return value != nil && re.MatchString(*value)
+}
+
+//Merge return the settings of the layers, each layer overriding the previous ones.
+//The thresholds of both directions of a layer (bandwidth-warning...) override the thresholds
+//of a direction of the previous layers (bandwidth-in-warning...), unless the layer also set them.
+func Merge(layers ...Settings) Settings {
+	merged := make(Settings)
+	for _, layer := range layers {
+		for key, value := range layer {
+			merged[key] = value
+			parts := strings.SplitN(key, "-", 2)
+			if len(parts) != 2 || (parts[1] != "warning" && parts[1] != "critical") {
+				continue
+			}
+			for _, direction := range []string{"in", "out"} {
+				directionKey := parts[0] + "-" + direction + "-" + parts[1]
+				if _, ok := layer[directionKey]; !ok {
+					delete(merged, directionKey)
+				}
+			}
+		}
+	}
+	return merged
+}
+
+func isKey(key string) bool {
+	for _, k := range Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
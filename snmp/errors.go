@@ -0,0 +1,84 @@
+package snmp
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	g "github.com/gosnmp/gosnmp"
+)
+
+// TimeoutError is returned when the agent hasn't answered to a request, retries included
+type TimeoutError struct {
+	Target string
+	Err    error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("No response from %v : %v", e.Target, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// AuthError is returned when the agent has rejected the SNMPv3 credentials
+type AuthError struct {
+	Target string
+	Err    error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("Authentication failure on %v : %v", e.Target, e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// RequestError convert the error returned by gosnmp for a request to a typed error when possible
+func RequestError(snmpConnection *g.GoSNMP, err error) error {
+	if err == nil {
+		return nil
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return &TimeoutError{Target: snmpConnection.Target, Err: err}
+	}
+	//gosnmp doesn't type its timeout error once the retries are exhausted
+	if strings.Contains(err.Error(), "timeout") {
+		return &TimeoutError{Target: snmpConnection.Target, Err: err}
+	}
+	if isAuthError(err) {
+		return &AuthError{Target: snmpConnection.Target, Err: err}
+	}
+	return err
+}
+
+// isAuthError check if the error is one of the gosnmp errors raised by a rejected SNMPv3 request
+func isAuthError(err error) bool {
+	for _, authErr := range []error{g.ErrUnknownUsername, g.ErrWrongDigest, g.ErrDecryption, g.ErrUnknownSecurityLevel} {
+		if errors.Is(err, authErr) {
+			return true
+		}
+	}
+	return false
+}
@@ -19,56 +19,97 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 */
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
+	g "github.com/gosnmp/gosnmp"
 	log "github.com/sirupsen/logrus"
-	g "github.com/soniah/gosnmp"
-	"github.com/spf13/cobra"
 )
 
+// Options contains all the parameters of the SNMP connection
+type Options struct {
+	// Version of the protocol : "1", "2c" or "3"
+	Version       string
+	Target        string
+	Port          int
+	Transport     string
+	SourceAddress string
+	Timeout       time.Duration
+	Retries       int
+	MaxOids       int
+	// Community used in version 1 and 2c
+	Community string
+	// Security parameters used in version 3
+	Username       string
+	SecLevel       string
+	AuthProtocol   string
+	AuthPassphrase string
+	PrivProtocol   string
+	PrivPassphrase string
+	Context        string
+}
+
 // CreateConnection create the SNMP connection depending on the version provided
-func CreateConnection(version string, cmd *cobra.Command) (*g.GoSNMP, error) {
-	timeout, _ := cmd.Flags().GetInt("timeout")
-	retry, _ := cmd.Flags().GetInt("retry")
+func CreateConnection(ctx context.Context, opts *Options) (*g.GoSNMP, error) {
+	version := opts.Version
+	transport := opts.Transport
+	if transport == "" {
+		transport = "udp"
+	}
+	port := opts.Port
+	if port == 0 {
+		port = 161
+	}
+	if port < 0 || port > 65535 {
+		return nil, fmt.Errorf("%v is not a valid port, check usage", port)
+	}
+	switch transport {
+	case "udp", "tcp", "udp6", "tcp6":
+	default:
+		return nil, fmt.Errorf("%v is not a valid transport, check usage", transport)
+	}
+	//gosnmp dials the tcp connection again from the default address when the session is reset,
+	//the source address can only be kept in udp
+	if len(opts.SourceAddress) > 0 && strings.HasPrefix(transport, "tcp") {
+		return nil, fmt.Errorf("The source address can't be used with the %v transport, only with udp or udp6", transport)
+	}
 	params := &g.GoSNMP{
-		Target:  cmd.Flag("hostname").Value.String(),
-		Port:    161,
-		Timeout: time.Duration(timeout) * time.Second,
-		Retries: retry,
+		Context:   ctx,
+		Target:    opts.Target,
+		Port:      uint16(port),
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		Retries:   opts.Retries,
+		MaxOids:   opts.MaxOids,
 	}
 	log.Debugf("Polling in version %v\n", version)
 	switch version {
+	case "1":
+		params.Version = g.Version1
+		params.Community = opts.Community
 	case "2c":
 		params.Version = g.Version2c
-		params.Community = cmd.Flag("community").Value.String()
+		params.Community = opts.Community
 	case "3":
 		params.Version = g.Version3
 		params.SecurityModel = g.UserSecurityModel
-		var authProto g.SnmpV3AuthProtocol = g.SHA
-		var privProto g.SnmpV3PrivProtocol = g.AES
 		var secLevel g.SnmpV3MsgFlags = g.AuthPriv
 
-		switch cmd.Flag("auth-protocol").Value.String() {
-		case "MD5", "md5":
-			authProto = g.MD5
-		case "SHA", "sha":
-			authProto = g.SHA
-		default:
-			return nil, fmt.Errorf("%v is not a valid authentication protocol, check usage", cmd.Flag("auth-protocol").Value.String())
+		authProto, err := parseAuthProtocol(opts.AuthProtocol)
+		if err != nil {
+			return nil, err
 		}
 
-		switch cmd.Flag("priv-protocol").Value.String() {
-		case "DES", "des":
-			privProto = g.DES
-		case "AES", "aes":
-			privProto = g.AES
-		default:
-			return nil, fmt.Errorf("%v is not a valid privacy protocol, check usage", cmd.Flag("priv-protocol").Value.String())
+		privProto, err := parsePrivProtocol(opts.PrivProtocol)
+		if err != nil {
+			return nil, err
 		}
 
-		switch cmd.Flag("sec-level").Value.String() {
+		switch opts.SecLevel {
 		case "noAuthNoPriv":
 			secLevel = g.NoAuthNoPriv
 			authProto = g.NoAuth
@@ -79,60 +120,103 @@ func CreateConnection(version string, cmd *cobra.Command) (*g.GoSNMP, error) {
 		case "authPriv":
 			secLevel = g.AuthPriv
 		default:
-			return nil, fmt.Errorf("%v is not a valid security level, check usage", cmd.Flag("sec-level").Value.String())
+			return nil, fmt.Errorf("%v is not a valid security level, check usage", opts.SecLevel)
 		}
 
 		params.MsgFlags = secLevel
+		params.ContextName = opts.Context
 		params.SecurityParameters = &g.UsmSecurityParameters{
-			UserName:                 cmd.Flag("username").Value.String(),
+			UserName:                 opts.Username,
 			AuthenticationProtocol:   authProto,
-			AuthenticationPassphrase: cmd.Flag("auth-passphrase").Value.String(),
+			AuthenticationPassphrase: opts.AuthPassphrase,
 			PrivacyProtocol:          privProto,
-			PrivacyPassphrase:        cmd.Flag("priv-passphrase").Value.String(),
+			PrivacyPassphrase:        opts.PrivPassphrase,
 		}
+	default:
+		return nil, fmt.Errorf("%v is not a valid SNMP version", version)
 	}
 
 	err := params.Connect()
 	if err != nil {
-		log.Fatalf("Connect() err: %v", err)
+		return nil, fmt.Errorf("connect error: %w", RequestError(params, err))
 	}
-
-	if version == "3" {
-		authRes, err := params.Get([]string{"1.3.6.1.2.1.1.1.0"})
+	if len(opts.SourceAddress) > 0 {
+		err = bindSourceAddress(params, opts.SourceAddress)
 		if err != nil {
+			params.Conn.Close()
 			return nil, err
 		}
-		if authRes.Error != g.NoError {
-			return nil, errors.New(authRes.Error.String())
-		}
+	}
 
-		if err := getErrorFromVariables(authRes.Variables); err != nil {
-			return nil, err
+	if version == "3" {
+		//gosnmp returns its sentinel errors when the agent rejects the credentials, RequestError types them
+		_, err := params.Get([]string{"1.3.6.1.2.1.1.1.0"})
+		if err != nil {
+			params.Conn.Close()
+			return nil, RequestError(params, err)
 		}
 	}
 
 	return params, nil
 }
 
-func oidToError(name string) (err error) {
-	switch name {
-	case ".1.3.6.1.6.3.15.1.1.3.0":
-		// usmStatsUnknownUserNames
-		err = errors.New("unknown user name")
-	case ".1.3.6.1.6.3.15.1.1.5.0":
-		// usmStatsWrongDigests
-		err = errors.New("wrong digests, possibly wrong password")
-	case "1.3.6.1.6.3.15.1.1.1.0":
-		err = errors.New("wrong or unavailable securityLevel")
+// bindSourceAddress replace the udp socket opened by gosnmp by a new one using the source address given in argument,
+// gosnmp doesn't allow to choose the local address of the socket. Connecting an udp socket doesn't send any packet.
+func bindSourceAddress(params *g.GoSNMP, sourceAddress string) error {
+	log.Debugf("Use %v as source address", sourceAddress)
+	localAddr, err := net.ResolveUDPAddr(params.Transport, net.JoinHostPort(sourceAddress, "0"))
+	if err != nil {
+		return fmt.Errorf("%v is not a valid source address : %v", sourceAddress, err)
+	}
+	dialer := net.Dialer{Timeout: params.Timeout, LocalAddr: localAddr}
+	conn, err := dialer.Dial(params.Transport, net.JoinHostPort(params.Target, strconv.Itoa(int(params.Port))))
+	if err != nil {
+		return fmt.Errorf("connect error from source address %v : %v", sourceAddress, err)
 	}
-	return
+	params.Conn.Close()
+	params.Conn = conn
+	return nil
 }
 
-func getErrorFromVariables(packets []g.SnmpPDU) error {
-	for _, variable := range packets {
-		if err := oidToError(variable.Name); err != nil {
-			return err
-		}
+// authProtocols maps the normalized names accepted by the auth-protocol flag to the gosnmp protocols
+var authProtocols = map[string]g.SnmpV3AuthProtocol{
+	"MD5":    g.MD5,
+	"SHA":    g.SHA,
+	"SHA224": g.SHA224,
+	"SHA256": g.SHA256,
+	"SHA384": g.SHA384,
+	"SHA512": g.SHA512,
+}
+
+// privProtocols maps the normalized names accepted by the priv-protocol flag to the gosnmp protocols,
+// the "C" suffix selects the Cisco (Reeder) key extension instead of the Blumenthal one
+var privProtocols = map[string]g.SnmpV3PrivProtocol{
+	"DES":     g.DES,
+	"AES":     g.AES,
+	"AES128":  g.AES,
+	"AES192":  g.AES192,
+	"AES256":  g.AES256,
+	"AES192C": g.AES192C,
+	"AES256C": g.AES256C,
+}
+
+// normalizeProtocol upper case the protocol name and remove the separators so "sha-256" and "SHA256" are the same
+func normalizeProtocol(name string) string {
+	return strings.NewReplacer("-", "", "_", "").Replace(strings.ToUpper(name))
+}
+
+func parseAuthProtocol(name string) (g.SnmpV3AuthProtocol, error) {
+	proto, ok := authProtocols[normalizeProtocol(name)]
+	if !ok {
+		return g.NoAuth, fmt.Errorf("%v is not a valid authentication protocol, check usage", name)
 	}
-	return nil
+	return proto, nil
+}
+
+func parsePrivProtocol(name string) (g.SnmpV3PrivProtocol, error) {
+	proto, ok := privProtocols[normalizeProtocol(name)]
+	if !ok {
+		return g.NoPriv, fmt.Errorf("%v is not a valid privacy protocol, check usage", name)
+	}
+	return proto, nil
 }
@@ -0,0 +1,160 @@
+package snmp
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	g "github.com/gosnmp/gosnmp"
+)
+
+// Report OIDs sent by an agent rejecting a SNMPv3 request
+const (
+	usmStatsUnknownEngineIDs = ".1.3.6.1.6.3.15.1.1.4.0"
+	usmStatsUnknownUserNames = ".1.3.6.1.6.3.15.1.1.3.0"
+	usmStatsWrongDigests     = ".1.3.6.1.6.3.15.1.1.5.0"
+)
+
+// startRejectingAgent start a SNMPv3 agent on the loopback answering the engine discovery then rejecting every request
+// with a report carrying the given OID, it's stopped at the end of the test
+func startRejectingAgent(t *testing.T, reportOid string) int {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Can't start the agent : %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		//The user name of the decoder is only required by gosnmp validation, the one of the request is read from the packet
+		decoder := &g.GoSNMP{
+			Version:            g.Version3,
+			SecurityModel:      g.UserSecurityModel,
+			SecurityParameters: &g.UsmSecurityParameters{UserName: "agent"},
+			Logger:             g.NewLogger(nil),
+		}
+		buffer := make([]byte, 65535)
+		for {
+			n, addr, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+			request, err := decoder.SnmpDecodePacket(buffer[:n])
+			if err != nil {
+				t.Errorf("Can't decode the request : %v", err)
+				continue
+			}
+			oid := reportOid
+			if request.SecurityParameters.(*g.UsmSecurityParameters).AuthoritativeEngineID == "" {
+				oid = usmStatsUnknownEngineIDs
+			}
+			report := &g.SnmpPacket{
+				Version:       g.Version3,
+				MsgFlags:      g.NoAuthNoPriv,
+				SecurityModel: g.UserSecurityModel,
+				SecurityParameters: &g.UsmSecurityParameters{
+					AuthoritativeEngineID:    "fake-engine",
+					AuthoritativeEngineBoots: 1,
+					AuthoritativeEngineTime:  1,
+					UserName:                 request.SecurityParameters.(*g.UsmSecurityParameters).UserName,
+				},
+				MsgID:     request.MsgID,
+				RequestID: request.RequestID,
+				PDUType:   g.Report,
+				Variables: []g.SnmpPDU{{Name: oid, Type: g.Counter32, Value: uint32(1)}},
+			}
+			response, err := report.MarshalMsg()
+			if err != nil {
+				t.Errorf("Can't encode the report : %v", err)
+				continue
+			}
+			conn.WriteToUDP(response, addr)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestCreateConnectionAuthError(t *testing.T) {
+	tests := []struct {
+		name      string
+		reportOid string
+		expected  error
+	}{
+		{"unknown user", usmStatsUnknownUserNames, g.ErrUnknownUsername},
+		{"wrong digest", usmStatsWrongDigests, g.ErrWrongDigest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			port := startRejectingAgent(t, test.reportOid)
+			_, err := CreateConnection(context.Background(), &Options{
+				Version:      "3",
+				Target:       "127.0.0.1",
+				Port:         port,
+				Timeout:      time.Second,
+				Username:     "unknown",
+				SecLevel:     "noAuthNoPriv",
+				AuthProtocol: "SHA",
+				PrivProtocol: "AES",
+			})
+			var authErr *AuthError
+			if !errors.As(err, &authErr) {
+				t.Fatalf("Expected an AuthError, got %v", err)
+			}
+			if !errors.Is(err, test.expected) {
+				t.Errorf("Expected the AuthError to wrap %v, got %v", test.expected, authErr.Err)
+			}
+		})
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	connection := &g.GoSNMP{Target: "192.0.2.1"}
+	for _, sentinel := range []error{g.ErrUnknownUsername, g.ErrWrongDigest, g.ErrDecryption, g.ErrUnknownSecurityLevel} {
+		var authErr *AuthError
+		if err := RequestError(connection, sentinel); !errors.As(err, &authErr) {
+			t.Errorf("Expected %v to be an AuthError, got %T", sentinel, err)
+		}
+	}
+	var timeoutErr *TimeoutError
+	if err := RequestError(connection, errors.New("request timeout (after 0 retries)")); !errors.As(err, &timeoutErr) {
+		t.Errorf("Expected a TimeoutError, got %T", err)
+	}
+	if err := RequestError(connection, nil); err != nil {
+		t.Errorf("Expected nil, got %v", err)
+	}
+}
+
+func TestCreateConnectionConnectError(t *testing.T) {
+	//The port is released before connecting, the tcp connection is refused
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Can't reserve a port : %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	_, err = CreateConnection(context.Background(), &Options{Version: "2c", Target: "127.0.0.1", Port: port, Transport: "tcp", Timeout: time.Second})
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		t.Errorf("Expected the connect error to wrap the network error, got %v", err)
+	}
+}
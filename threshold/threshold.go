@@ -0,0 +1,135 @@
+package threshold
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//Range is a threshold in the Nagios range format [@]start:end.
+//An alert is raised when the value is outside of the range, or inside of it when the range is prefixed by @.
+//The start is 0 when omitted and can be ~ for -infinity, the end is +infinity when omitted :
+//  10      alert if < 0 or > 10
+//  10:     alert if < 10
+//  ~:10    alert if > 10
+//  10:20   alert if < 10 or > 20
+//  @10:20  alert if >= 10 and <= 20
+type Range struct {
+	Start  float64
+	End    float64
+	Inside bool
+}
+
+//Parse read and check a range given in the Nagios format
+func Parse(s string) (*Range, error) {
+	r := &Range{End: math.Inf(1)}
+	value := strings.TrimSpace(s)
+	if strings.HasPrefix(value, "@") {
+		r.Inside = true
+		value = value[1:]
+	}
+	if value == "" {
+		return nil, fmt.Errorf("Invalid threshold range '%v' : empty range", s)
+	}
+
+	var err error
+	end := value
+	if i := strings.Index(value, ":"); i >= 0 {
+		start := value[:i]
+		end = value[i+1:]
+		switch start {
+		case "~":
+			r.Start = math.Inf(-1)
+		case "":
+			return nil, fmt.Errorf("Invalid threshold range '%v' : missing start before ':'", s)
+		default:
+			if r.Start, err = strconv.ParseFloat(start, 64); err != nil {
+				return nil, fmt.Errorf("Invalid threshold range '%v' : %v isn't a number", s, start)
+			}
+		}
+	}
+	if end != "" {
+		if r.End, err = strconv.ParseFloat(end, 64); err != nil {
+			return nil, fmt.Errorf("Invalid threshold range '%v' : %v isn't a number", s, end)
+		}
+	}
+	if r.Start > r.End {
+		return nil, fmt.Errorf("Invalid threshold range '%v' : the start is greater than the end", s)
+	}
+	return r, nil
+}
+
+//Alert test if the value raises an alert
+func (r *Range) Alert(value float64) bool {
+	inside := value >= r.Start && value <= r.End
+	return inside == r.Inside
+}
+
+//Describe return the condition met by the value for the output, ex: "> 80%", "< 10%" or "within 10% and 20%"
+func (r *Range) Describe(value float64, unit string) string {
+	return r.DescribeFunc(value, func(f float64) string { return formatBound(f) + unit })
+}
+
+//DescribeFunc is Describe with the bounds formatted by the given function
+func (r *Range) DescribeFunc(value float64, format func(float64) string) string {
+	switch {
+	case r.Inside:
+		return fmt.Sprintf("within %v and %v", format(r.Start), format(r.End))
+	case value < r.Start:
+		return fmt.Sprintf("< %v", format(r.Start))
+	default:
+		return fmt.Sprintf("> %v", format(r.End))
+	}
+}
+
+//Above test if the value raises an alert because it's greater than the end of the range
+func (r *Range) Above(value float64) bool {
+	return !r.Inside && value > r.End
+}
+
+//String return the range in the Nagios format, used in the warn and crit fields of the perfdata
+func (r *Range) String() string {
+	var s string
+	if r.Inside {
+		s = "@"
+	}
+	switch {
+	case math.IsInf(r.Start, -1):
+		s += "~:"
+	case r.Start != 0 || math.IsInf(r.End, 1):
+		s += formatBound(r.Start) + ":"
+	}
+	if !math.IsInf(r.End, 1) {
+		s += formatBound(r.End)
+	}
+	return s
+}
+
+func formatBound(f float64) string {
+	switch {
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsInf(f, 1):
+		return "inf"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
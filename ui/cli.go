@@ -25,6 +25,9 @@ func CliSummary(intNewData *netint.InterfaceDetails, chk *sknchk.Check) {
 		chk.AddShort(fmt.Sprintf("Alias : %v", *intNewData.IfAlias), true)
 	}
 	chk.AddShort(fmt.Sprintf("Speed : %v", convert.HumanReadable(float64(*intNewData.SpeedInbit), 1000, "bps")), true)
+	if len(intNewData.Stack) > 0 {
+		chk.AddShort(fmt.Sprintf("Stack : %v", intNewData.StackPath()), true)
+	}
 	if intNewData.IfOperStatus != nil {
 		if *intNewData.IfOperStatus == netint.UP {
 			chk.AddShort("Oper Status : UP", true)
@@ -116,8 +119,8 @@ func CliSummary(intNewData *netint.InterfaceDetails, chk *sknchk.Check) {
 	chk.AddShort(outRateStr+outPrctStr, true)
 
 	if intNewData.IfInErrorsRate != nil {
-		if intNewData.LocIfInCRCRate != nil {
-			chk.AddShort(fmt.Sprintf("In Errors : %.2f pps (%.2f%%) with %.2f pps CRC Errors", *intNewData.IfInErrorsRate, *intNewData.IfInErrorsPrct, *intNewData.LocIfInCRCRate), true)
+		if crcRate := intNewData.CRCRate(); crcRate != nil {
+			chk.AddShort(fmt.Sprintf("In Errors : %.2f pps (%.2f%%) with %.2f pps CRC Errors", *intNewData.IfInErrorsRate, *intNewData.IfInErrorsPrct, *crcRate), true)
 		} else {
 			chk.AddShort(fmt.Sprintf("In Errors : %.2f pps (%.2f%%), no additional CRC Error stat", *intNewData.IfInErrorsRate, *intNewData.IfInErrorsPrct), true)
 		}
@@ -140,3 +143,39 @@ func CliSummary(intNewData *netint.InterfaceDetails, chk *sknchk.Check) {
 		chk.AddShort("Out Discards : Can't be determined", true)
 	}
 }
+
+//InterfaceSummary generate a single line summary of the interface, used when several interfaces are checked in a single run
+func InterfaceSummary(intNewData *netint.InterfaceDetails, status string) string {
+	var name string
+	if intNewData.IfName != nil {
+		name = *intNewData.IfName
+	} else if intNewData.IfDescr != nil {
+		name = *intNewData.IfDescr
+	}
+	if intNewData.IfAlias != nil && len(*intNewData.IfAlias) > 0 {
+		name += fmt.Sprintf(" (%v)", *intNewData.IfAlias)
+	}
+	summary := fmt.Sprintf("%v : %v", name, status)
+	if intNewData.IfInRate != nil {
+		summary += fmt.Sprintf(", In %v", convert.HumanReadable(*intNewData.IfInRate, 1024, "bits/sec"))
+		if intNewData.IfInPrct != nil {
+			summary += fmt.Sprintf(" (%.2f%%)", *intNewData.IfInPrct)
+		}
+	}
+	if intNewData.IfOutRate != nil {
+		summary += fmt.Sprintf(", Out %v", convert.HumanReadable(*intNewData.IfOutRate, 1024, "bits/sec"))
+		if intNewData.IfOutPrct != nil {
+			summary += fmt.Sprintf(" (%.2f%%)", *intNewData.IfOutPrct)
+		}
+	}
+	if intNewData.IfInErrorsRate != nil && intNewData.IfOutErrorsRate != nil {
+		summary += fmt.Sprintf(", Errors %.2f/%.2f pps", *intNewData.IfInErrorsRate, *intNewData.IfOutErrorsRate)
+	}
+	if intNewData.IfInDiscardsRate != nil && intNewData.IfOutDiscardsRate != nil {
+		summary += fmt.Sprintf(", Discards %.2f/%.2f pps", *intNewData.IfInDiscardsRate, *intNewData.IfOutDiscardsRate)
+	}
+	if intNewData.Dot3StatsDuplexStatus != nil {
+		summary += fmt.Sprintf(", %v", netint.DuplexToString(*intNewData.Dot3StatsDuplexStatus))
+	}
+	return summary
+}
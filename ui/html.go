@@ -23,10 +23,12 @@ import (
 	"fmt"
 	"html/template"
 	"regexp"
-	"strings"
+	"time"
 
 	"go-check-network-interface/convert"
 	"go-check-network-interface/netint"
+
+	sknchk "github.com/pandaoc-io/go-shinken-check"
 )
 
 //TableTmpl is the HTML code to generate the table into the long output
@@ -39,6 +41,11 @@ const TableTmpl = `
               <tr>
                 <th colspan="6" style="color: #1d72aa; background-color: #eef6fc; padding: 5px; text-align: center;">Alias : {{if .IfAlias}}{{if eq (len .IfAlias) 0 }}Alias is empty{{else}}{{.IfAlias}}{{end}}{{else}}No alias found{{end}}{{if IsCritical}} <span style="font-size: large; color: #721c24">&#9762;</span> <span style="color: #721c24">Critical interface detected</span> <span style="font-size: large; color: #721c24">&#9762;</span>{{end}}</th>
               </tr>
+              {{if .Stack -}}
+              <tr>
+                <th colspan="6" style="color: #1d72aa; background-color: #eef6fc; padding: 5px; text-align: center;">Stack : {{.StackPath}}{{with .DownLayer}} - <span style="color: #721c24">Parent {{.Name}} is {{StatusIntToStr .OperStatus}}</span>{{end}}</th>
+              </tr>
+              {{end -}}
               <tr style="background-color: rgba(0,0,0,.075);">
                 <th colspan="2" style="padding: 5px;">Oper Status</th>
                 <th colspan="2" style="padding: 5px;">Admin Status</th>
@@ -47,11 +54,11 @@ const TableTmpl = `
               </tr>
               <tr>
                 {{if eq (StatusIntToStr .IfOperStatus) "UP" -}}
-                  <td colspan="2" style="text-align: center; background-color: #d4edda; color: #155724; padding: 5px;">{{StatusIntToStr .IfOperStatus}} &#10004;</td>
+                  <td colspan="2" style="text-align: center; background-color: #d4edda; color: #155724; padding: 5px;">{{StatusIntToStr .IfOperStatus}} &#10004;{{with LastChange}}<br>Last change {{.}} ago{{end}}</td>
                 {{else if eq (StatusIntToStr .IfOperStatus) "DOWN" -}}
-                  <td colspan="2" style="text-align: center; background-color: #f8d7da; color: #721c24; padding: 5px;">{{StatusIntToStr .IfOperStatus}} &#10006;</td>
+                  <td colspan="2" style="text-align: center; background-color: #f8d7da; color: #721c24; padding: 5px;">{{StatusIntToStr .IfOperStatus}} &#10006;{{with LastChange}}<br>Last change {{.}} ago{{end}}</td>
                 {{else -}}
-                  <td colspan="2" style="text-align: center; background-color: #fff3cd; color: #856404; padding: 5px;">{{StatusIntToStr .IfOperStatus}} &#8264;</td>
+                  <td colspan="2" style="text-align: center; background-color: #fff3cd; color: #856404; padding: 5px;">{{StatusIntToStr .IfOperStatus}} &#8264;{{with LastChange}}<br>Last change {{.}} ago{{end}}</td>
                 {{end -}}
                 {{if eq (StatusIntToStr .IfAdminStatus) "UP" -}}
                   <td colspan="2" style="text-align: center; background-color: #d4edda; color: #155724; padding: 5px;">{{StatusIntToStr .IfAdminStatus}} &#10004;</td>
@@ -79,9 +86,9 @@ const TableTmpl = `
               </tr>
               <tr>
                 {{if .IfInRate -}}
-                {{if eq (CompPnF .IfInPrct BwCritThreshold) 1 -}}
+                {{if Critical (Tested "bandwidth" "in") -}}
                   <td colspan="2" style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{if .IfInRate}}{{HumanBps .IfInRate}}{{end}}{{if .IfInPrct}} &#11020; {{Float2f .IfInPrct}} %{{end}}</td>
-                {{else if eq (CompPnF .IfInPrct BwWarnThreshold) 1 -}}
+                {{else if Warning (Tested "bandwidth" "in") -}}
                   <td colspan="2" style="background-color: #fff3cd; color: #856404; padding: 5px;">{{if .IfInRate}}{{HumanBps .IfInRate}}{{end}}{{if .IfInPrct}} &#11020;  {{Float2f .IfInPrct}} %{{end}}</td>
                 {{else -}}
                   <td colspan="2" style="background-color: #d4edda; color: #155724; padding: 5px;">{{if .IfInRate}}{{HumanBps .IfInRate}}{{end}}{{if .IfInPrct}} &#11020; {{Float2f .IfInPrct}} %{{end}}</td>
@@ -90,9 +97,9 @@ const TableTmpl = `
                 <td colspan="2" style="padding: 5px;">N/A</td>
                 {{end -}}
                 {{if .IfOutRate -}}
-                {{if eq (CompPnF .IfOutPrct BwCritThreshold) 1 -}}
+                {{if Critical (Tested "bandwidth" "out") -}}
                   <td colspan="2" style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{if .IfOutRate}}{{HumanBps .IfOutRate}}{{end}}{{if .IfOutPrct}} &#11020; {{Float2f .IfOutPrct}} %{{end}}</td>
-                {{else if eq (CompPnF .IfOutPrct BwWarnThreshold) 1 -}}
+                {{else if Warning (Tested "bandwidth" "out") -}}
                   <td colspan="2" style="background-color: #fff3cd; color: #856404; padding: 5px;">{{if .IfOutRate}}{{HumanBps .IfOutRate}}{{end}}{{if .IfOutPrct}} &#11020; {{Float2f .IfOutPrct}} %{{end}}</td>
                 {{else -}}
                   <td colspan="2" style="background-color: #d4edda; color: #155724; padding: 5px;">{{if .IfOutRate}}{{HumanBps .IfOutRate}}{{end}}{{if .IfOutPrct}} &#11020; {{Float2f .IfOutPrct}} %{{end}}</td>
@@ -100,8 +107,8 @@ const TableTmpl = `
                 {{else -}}
                 <td colspan="2" style="padding: 5px;">N/A</td>
                 {{end -}}
-                <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{BwWarnThreshold}} %</td>
-                <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{BwCritThreshold}} %</td>
+                <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{ShowThreshold "bandwidth" "warn"}}</td>
+                <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{ShowThreshold "bandwidth" "crit"}}</td>
               </tr>
               <tr style="background-color: rgba(0,0,0,.075)">
                 <th style="padding: 5px;">In Packets</th>
@@ -117,18 +124,18 @@ const TableTmpl = `
                 {{if .InMultiPcktRate}}&#10148; Multicast: {{Float2f .InMultiPcktRate}} pps<br>{{end -}}
                 {{if .InBroadPcktRate}}&#10148; Broadcast: {{Float2f .InBroadPcktRate}} pps{{end -}}{{end -}}
                 </td>
-                {{if and (eq ErrUnitThreshold "pps") .IfInErrorsRate -}}
-                  {{if eq (CompPnF .IfInErrorsRate ErrCritThreshold) 1 -}}
+                {{if and (eq (Limits "errors" "in").Unit "pps") .IfInErrorsRate -}}
+                  {{if Critical (Tested "errors" "in") -}}
                     <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{Float2f .IfInErrorsRate}} pps &#11020; {{if .IfInErrorsPrct}}{{Float2f .IfInErrorsPrct}} %{{end}}</td>
-                  {{else if eq (CompPnF .IfInErrorsRate ErrWarnThreshold) 1 -}}
+                  {{else if Warning (Tested "errors" "in") -}}
                     <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{Float2f .IfInErrorsRate}} pps &#11020; {{if .IfInErrorsPrct}}{{Float2f .IfInErrorsPrct}} %{{end}}</td>
                   {{else -}}
                     <td style="background-color: #d4edda; color: #155724; padding: 5px;">{{Float2f .IfInErrorsRate}} pps &#11020; {{if .IfInErrorsPrct}}{{Float2f .IfInErrorsPrct}} %{{end}}</td>
                   {{end -}}
-                {{else if and (eq ErrUnitThreshold "%") .IfInErrorsPrct -}}
-                  {{if eq (CompPnF .IfInErrorsPrct ErrCritThreshold) 1 -}}
+                {{else if and (eq (Limits "errors" "in").Unit "%") .IfInErrorsPrct -}}
+                  {{if Critical (Tested "errors" "in") -}}
                     <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{if .IfInErrorsRate}}{{Float2f .IfInErrorsRate}} pps{{end}} &#11020; {{Float2f .IfInErrorsPrct}} %</td>
-                  {{else if eq (CompPnF .IfInErrorsPrct ErrWarnThreshold) 1 -}}
+                  {{else if Warning (Tested "errors" "in") -}}
                     <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{if .IfInErrorsRate}}{{Float2f .IfInErrorsRate}} pps{{end}} &#11020; {{Float2f .IfInErrorsPrct}} %</td>
                   {{else -}}
                     <td style="background-color: #d4edda; color: #155724; padding: 5px;">{{if .IfInErrorsRate}}{{Float2f .IfInErrorsRate}} pps{{end}} &#11020; {{Float2f .IfInErrorsPrct}} %</td>
@@ -141,18 +148,18 @@ const TableTmpl = `
                 {{if .OutMultiPcktRate}}&#10148; Multicast: {{Float2f .OutMultiPcktRate}} pps<br>{{end -}}
                 {{if .OutBroadPcktRate}}&#10148; Broadcast: {{Float2f .OutBroadPcktRate}} pps<br>{{end -}}{{end -}}
                 </td>
-                {{if and (eq ErrUnitThreshold "pps") .IfOutErrorsRate -}}
-                  {{if eq (CompPnF .IfOutErrorsRate ErrCritThreshold) 1 -}}
+                {{if and (eq (Limits "errors" "out").Unit "pps") .IfOutErrorsRate -}}
+                  {{if Critical (Tested "errors" "out") -}}
                     <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{Float2f .IfOutErrorsRate}} pps &#11020; {{if .IfOutErrorsPrct}}{{Float2f .IfOutErrorsPrct}} %{{end}}</td>
-                  {{else if eq (CompPnF .IfOutErrorsRate ErrWarnThreshold) 1 -}}
+                  {{else if Warning (Tested "errors" "out") -}}
                     <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{Float2f .IfOutErrorsRate}} pps &#11020; {{if .IfOutErrorsPrct}}{{Float2f .IfOutErrorsPrct}} %{{end}}</td>
                   {{else -}}
                     <td style="background-color: #d4edda; color: #155724; padding: 5px;">{{Float2f .IfOutErrorsRate}} pps &#11020; {{if .IfOutErrorsPrct}}{{Float2f .IfOutErrorsPrct}} %{{end}}</td>
                   {{end -}}
-                {{else if and (eq ErrUnitThreshold "%") .IfOutErrorsPrct -}}
-                  {{if eq (CompPnF .IfOutErrorsPrct ErrCritThreshold) 1 -}}
+                {{else if and (eq (Limits "errors" "out").Unit "%") .IfOutErrorsPrct -}}
+                  {{if Critical (Tested "errors" "out") -}}
                     <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{if .IfOutErrorsRate}}{{Float2f .IfOutErrorsRate}} pps{{end}} &#11020; {{Float2f .IfOutErrorsPrct}} %</td>
-                  {{else if eq (CompPnF .IfOutErrorsPrct ErrWarnThreshold) 1 -}}
+                  {{else if Warning (Tested "errors" "out") -}}
                     <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{if .IfOutErrorsRate}}{{Float2f .IfOutErrorsRate}} pps{{end}} &#11020; {{Float2f .IfOutErrorsPrct}} %</td>
                   {{else -}}
                     <td style="background-color: #d4edda; color: #155724; padding: 5px;">{{if .IfOutErrorsRate}}{{Float2f .IfOutErrorsRate}} pps{{end}} &#11020; {{Float2f .IfOutErrorsPrct}} %</td>
@@ -160,8 +167,8 @@ const TableTmpl = `
                 {{else -}}
                   <td style="padding: 5px;">N/A</td>
                 {{end -}}
-                <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{ErrWarnThreshold}} {{ErrUnitThreshold}}</td>
-                <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{ErrCritThreshold}} {{ErrUnitThreshold}}</td>
+                <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{ShowThreshold "errors" "warn"}}</td>
+                <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{ShowThreshold "errors" "crit"}}</td>
               </tr>
               <tr style="background-color: rgba(0,0,0,.075)">
                 <th style="padding: 5px;">In discards</th>
@@ -170,18 +177,18 @@ const TableTmpl = `
                 <th style="padding: 5px;">Discards Critical<br>threshold</th>
               </tr>
               <tr>
-                {{if and (eq DisUnitThreshold "pps") .IfInDiscardsRate -}}
-                  {{if eq (CompPnF .IfInDiscardsRate DisCritThreshold) 1 -}}
+                {{if and (eq (Limits "discards" "in").Unit "pps") .IfInDiscardsRate -}}
+                  {{if Critical (Tested "discards" "in") -}}
                     <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{Float2f .IfInDiscardsRate}} pps &#11020; {{if .IfInDiscardsPrct}}{{Float2f .IfInDiscardsPrct}} %{{end}}</td>
-                  {{else if eq (CompPnF .IfInDiscardsRate DisWarnThreshold) 1 -}}
+                  {{else if Warning (Tested "discards" "in") -}}
                     <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{Float2f .IfInDiscardsRate}} pps &#11020; {{if .IfInDiscardsPrct}}{{Float2f .IfInDiscardsPrct}} %{{end}}</td>
                   {{else -}}
                     <td style="background-color: #d4edda; color: #155724; padding: 5px;">{{Float2f .IfInDiscardsRate}} pps &#11020; {{if .IfInDiscardsPrct}}{{Float2f .IfInDiscardsPrct}} %{{end}}</td>
                   {{end -}}
-                {{else if and (eq DisUnitThreshold "%") .IfInDiscardsPrct -}}
-                  {{if eq (CompPnF .IfInDiscardsPrct DisCritThreshold) 1 -}}
+                {{else if and (eq (Limits "discards" "in").Unit "%") .IfInDiscardsPrct -}}
+                  {{if Critical (Tested "discards" "in") -}}
                     <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{if .IfInDiscardsRate}}{{Float2f .IfInDiscardsRate}} pps{{end}} &#11020; {{Float2f .IfInDiscardsPrct}} %</td>
-                  {{else if eq (CompPnF .IfInDiscardsPrct DisWarnThreshold) 1 -}}
+                  {{else if Warning (Tested "discards" "in") -}}
                     <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{if .IfInDiscardsRate}}{{Float2f .IfInDiscardsRate}} pps{{end}} &#11020; {{Float2f .IfInDiscardsPrct}} %</td>
                   {{else -}}
                     <td style="background-color: #d4edda; color: #155724; padding: 5px;">{{if .IfInDiscardsRate}}{{Float2f .IfInDiscardsRate}} pps{{end}} &#11020; {{Float2f .IfInDiscardsPrct}} %</td>
@@ -190,18 +197,18 @@ const TableTmpl = `
                   <td style="padding: 5px;">N/A</td>
                 {{end -}}
 
-                {{if and (eq DisUnitThreshold "pps") .IfOutDiscardsRate -}}
-                  {{if eq (CompPnF .IfOutDiscardsRate DisCritThreshold) 1 -}}
+                {{if and (eq (Limits "discards" "out").Unit "pps") .IfOutDiscardsRate -}}
+                  {{if Critical (Tested "discards" "out") -}}
                     <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{Float2f .IfOutDiscardsRate}} pps &#11020; {{if .IfOutDiscardsPrct}}{{Float2f .IfOutDiscardsPrct}} %{{end}}</td>
-                  {{else if eq (CompPnF .IfOutDiscardsRate DisWarnThreshold) 1 -}}
+                  {{else if Warning (Tested "discards" "out") -}}
                     <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{Float2f .IfOutDiscardsRate}} pps &#11020; {{if .IfOutDiscardsPrct}}{{Float2f .IfOutDiscardsPrct}} %{{end}}</td>
                   {{else -}}
                     <td style="background-color: #d4edda; color: #155724; padding: 5px;">{{Float2f .IfOutDiscardsRate}} pps &#11020; {{if .IfOutDiscardsPrct}}{{Float2f .IfOutDiscardsPrct}} %{{end}}</td>
                   {{end -}}
-                {{else if and (eq DisUnitThreshold "%") .IfOutDiscardsPrct -}}
-                  {{if eq (CompPnF .IfOutDiscardsPrct DisCritThreshold) 1 -}}
+                {{else if and (eq (Limits "discards" "out").Unit "%") .IfOutDiscardsPrct -}}
+                  {{if Critical (Tested "discards" "out") -}}
                     <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{if .IfOutDiscardsRate}}{{Float2f .IfOutDiscardsRate}} pps{{end}} &#11020; {{Float2f .IfOutDiscardsPrct}} %</td>
-                  {{else if eq (CompPnF .IfOutDiscardsPrct DisWarnThreshold) 1 -}}
+                  {{else if Warning (Tested "discards" "out") -}}
                     <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{if .IfOutDiscardsRate}}{{Float2f .IfOutDiscardsRate}} pps{{end}} &#11020; {{Float2f .IfOutDiscardsPrct}} %</td>
                   {{else -}}
                     <td style="background-color: #d4edda; color: #155724; padding: 5px;">{{if .IfOutDiscardsRate}}{{Float2f .IfOutDiscardsRate}} pps{{end}} &#11020; {{Float2f .IfOutDiscardsPrct}} %</td>
@@ -209,22 +216,69 @@ const TableTmpl = `
                 {{else -}}
                 <td style="padding: 5px;">N/A</td>
                 {{end -}}
-                <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{DisWarnThreshold}} {{DisUnitThreshold}}</td>
-                <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{DisCritThreshold}} {{DisUnitThreshold}}</td>
+                <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{ShowThreshold "discards" "warn"}}</td>
+                <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{ShowThreshold "discards" "crit"}}</td>
+              </tr>
+              {{if .Optics -}}
+              <tr style="background-color: rgba(0,0,0,.075)">
+                <th style="padding: 5px;">Rx Power</th>
+                <th style="padding: 5px;">Tx Power</th>
+                <th style="padding: 5px;">Temperature</th>
+                <th style="padding: 5px;">Bias Current</th>
+                <th style="padding: 5px;">Voltage</th>
+                <th style="padding: 5px;">Optics MIB</th>
+              </tr>
+              <tr>
+                {{range $label := OpticsMetrics -}}
+                {{$value := OpticsValue $label -}}
+                {{$limits := OpticsLimits $label -}}
+                {{if not $value -}}
+                  <td style="padding: 5px;">N/A</td>
+                {{else if Critical $label -}}
+                  <td style="background-color: #f8d7da; color: #721c24; padding: 5px;">{{Float2f $value}} {{$limits.Unit}}</td>
+                {{else if Warning $label -}}
+                  <td style="background-color: #fff3cd; color: #856404; padding: 5px;">{{Float2f $value}} {{$limits.Unit}}</td>
+                {{else -}}
+                  <td style="background-color: #d4edda; color: #155724; padding: 5px;">{{Float2f $value}} {{$limits.Unit}}</td>
+                {{end -}}
+                {{end -}}
+                <td style="padding: 5px;">{{.Optics.Source}}</td>
+              </tr>
+              {{end -}}
+              {{if .Lag -}}
+              <tr>
+                <th colspan="6" style="color: #1d72aa; background-color: #eef6fc; padding: 5px; text-align: center;">Aggregation : {{.Lag.ActiveMembers}}/{{len .Lag.Members}} members active - Capacity : {{HumanRate .Lag.ActiveCapacity}} of {{HumanRate .Lag.TotalCapacity}}</th>
+              </tr>
+              <tr style="background-color: rgba(0,0,0,.075)">
+                <th colspan="2" style="padding: 5px;">Member</th>
+                <th style="padding: 5px;">Oper Status</th>
+                <th style="padding: 5px;">Speed</th>
+                <th style="padding: 5px;">In Bandwidth &#10563;</th>
+                <th style="padding: 5px;">Out Bandwidth &#10562;</th>
+              </tr>
+              {{range .Lag.Members -}}
+              <tr>
+                <td colspan="2" style="padding: 5px;">{{.Name}}</td>
+                {{if .Active -}}
+                  <td style="text-align: center; background-color: #d4edda; color: #155724; padding: 5px;">{{StatusIntToStr .OperStatus}} &#10004;</td>
+                {{else if not .Attached -}}
+                  <td style="text-align: center; background-color: #f8d7da; color: #721c24; padding: 5px;">{{StatusIntToStr .OperStatus}}, not attached &#10006;</td>
+                {{else -}}
+                  <td style="text-align: center; background-color: #f8d7da; color: #721c24; padding: 5px;">{{StatusIntToStr .OperStatus}} &#10006;</td>
+                {{end -}}
+                <td style="padding: 5px;">{{if .Speed}}{{HumanRate .Speed}}{{else}}N/A{{end}}</td>
+                <td style="padding: 5px;">{{if .InRate}}{{HumanBps .InRate}}{{else}}N/A{{end}}</td>
+                <td style="padding: 5px;">{{if .OutRate}}{{HumanBps .OutRate}}{{else}}N/A{{end}}</td>
               </tr>
+              {{end -}}
+              {{end -}}
             </tbody>
             </table>
 `
 
-//Thresholds is used to transfert thresholds value to build the table HTML template
-type Thresholds struct {
-	Bw, Bc                         float64
-	Ewflag, Ecflag, Dwflag, Dcflag string
-	Ew, Ec, Dw, Dc                 float64
-}
-
-//GenerateHTMLTable generate the HTML table of the long output details in string format
-func GenerateHTMLTable(intNewData *netint.InterfaceDetails, threshold *Thresholds) (string, error) {
+//GenerateHTMLTable generate the HTML table of the long output details in string format.
+//The cells are colored with the results of the evaluation, not with the current values.
+func GenerateHTMLTable(intNewData *netint.InterfaceDetails, threshold *Thresholds, eval *netint.Evaluation) (string, error) {
 	t := template.Must(template.New("table").Funcs(template.FuncMap{
 		"Float2f": func(f float64) string { return fmt.Sprintf("%.2f", f) },
 		"StatusIntToStr": func(st *uint) string {
@@ -246,38 +300,69 @@ func GenerateHTMLTable(intNewData *netint.InterfaceDetails, threshold *Threshold
 			}
 			return re.Match([]byte(*intNewData.IfAlias))
 		},
-		"HumanBps":         func(f float64) string { return convert.HumanReadable(f, 1024, "bits/sec") },
-		"HumanSpeed":       func() string { return convert.HumanReadable(float64(*intNewData.SpeedInbit), 1000, "bps") },
-		"BwCritThreshold":  func() float64 { return threshold.Bc },
-		"BwWarnThreshold":  func() float64 { return threshold.Bw },
-		"ErrCritThreshold": func() float64 { return threshold.Ec },
-		"ErrWarnThreshold": func() float64 { return threshold.Ew },
-		"ErrUnitThreshold": func() string {
-			if strings.Contains(threshold.Ecflag, "pps") {
-				return "pps"
+		"LastChange": func() string {
+			if since, ok := intNewData.LastChange(); ok {
+				return since.Round(time.Second).String()
+			}
+			return ""
+		},
+		"HumanBps":  func(f float64) string { return convert.HumanReadable(f, 1024, "bits/sec") },
+		"HumanRate": func(speed uint) string { return convert.HumanReadable(float64(speed), 1000, "bps") },
+		"HumanSpeed": func() string {
+			speed := convert.HumanReadable(float64(*intNewData.SpeedInbit), 1000, "bps")
+			if intNewData.InSpeed != nil && intNewData.OutSpeed != nil && (*intNewData.InSpeed != *intNewData.SpeedInbit || *intNewData.OutSpeed != *intNewData.SpeedInbit) {
+				speed += fmt.Sprintf(" (overridden : in %v, out %v)", convert.HumanReadable(float64(*intNewData.InSpeed), 1000, "bps"),
+					convert.HumanReadable(float64(*intNewData.OutSpeed), 1000, "bps"))
 			}
-			return "%"
+			return speed
+		},
+		"Limits": func(metric string, direction string) netint.Limits {
+			return threshold.limits(metric, direction)
 		},
-		"DisCritThreshold": func() float64 { return threshold.Dc },
-		"DisWarnThreshold": func() float64 { return threshold.Dw },
-		"DisUnitThreshold": func() string {
-			if strings.Contains(threshold.Dcflag, "pps") {
-				return "pps"
+		//Tested return the label of the metric tested against the thresholds, depending of their unit
+		"Tested": func(metric string, direction string) string {
+			unit := threshold.limits(metric, direction).Unit
+			switch {
+			case metric == "bandwidth" && unit == "%":
+				return direction + "_usage"
+			case metric == "bandwidth":
+				return direction
+			case unit == "%":
+				return direction + "_" + metric + "_prct"
+			default:
+				return direction + "_" + metric
 			}
-			return "%"
 		},
-		"CompPnF": func(f1 *float64, f2 float64) int {
-			if f1 == nil {
-				return -1
+		"ShowThreshold": func(metric string, level string) template.HTML {
+			show := func(limits netint.Limits) string {
+				if limits.Disabled {
+					return "disabled"
+				}
+				if level == "warn" {
+					return fmt.Sprintf("%v %v", limits.Warn, limits.Unit)
+				}
+				return fmt.Sprintf("%v %v", limits.Crit, limits.Unit)
 			}
-			if *f1 > f2 {
-				return 1
-			} else if *f1 == f2 {
-				return 0
-			} else {
-				return -1
+			in, out := show(threshold.limits(metric, "in")), show(threshold.limits(metric, "out"))
+			if in == out {
+				return template.HTML(template.HTMLEscapeString(in))
 			}
+			return template.HTML("In : " + template.HTMLEscapeString(in) + "<br>Out : " + template.HTMLEscapeString(out))
+		},
+		"OpticsMetrics": netint.OpticsMetrics,
+		"OpticsValue": func(label string) *float64 {
+			if sensor, _ := intNewData.Optics.Measure(label, threshold.Optics.Limits); sensor != nil {
+				return &sensor.Value
+			}
+			return nil
+		},
+		"OpticsLimits": func(label string) netint.Limits {
+			_, limits := intNewData.Optics.Measure(label, threshold.Optics.Limits)
+			return limits
 		},
+		//The disabled checks are never tested, their values are displayed without alert
+		"Critical": func(metric string) bool { return eval.State(metric) == sknchk.RcCritical },
+		"Warning":  func(metric string) bool { return eval.State(metric) == sknchk.RcWarning },
 	}).Parse(TableTmpl))
 	var tpl bytes.Buffer
 	err := t.Execute(&tpl, intNewData)
@@ -0,0 +1,68 @@
+package ui
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"strconv"
+
+	"go-check-network-interface/netint"
+	"go-check-network-interface/profile"
+
+	sknchk "github.com/pandaoc-io/go-shinken-check"
+)
+
+//operStatusSettings are the settings of the state of each oper status other than UP
+var operStatusSettings = map[string]uint{
+	"oper-down":        netint.DOWN,
+	"testing":          netint.TESTING,
+	"oper-unknown":     netint.UNKNOWN,
+	"dormant":          netint.DORMANT,
+	"not-present":      netint.NOTPRESENT,
+	"lower-layer-down": netint.LOWERLAYERDOWN,
+}
+
+//NewStatusPolicy read and check the state of each admin/oper status (admin-down: ok, dormant: warning...)
+//and the expect-down setting. The state of an empty setting is Ok for admin-down and Critical for the oper status.
+func NewStatusPolicy(settings profile.Settings) (*netint.StatusPolicy, error) {
+	policy := &netint.StatusPolicy{Oper: make(map[uint]sknchk.Status)}
+	var err error
+	if settings["admin-down"] != "" {
+		policy.AdminDown, err = netint.ParseState(settings["admin-down"])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid admin-down state : %v. See usage for more details.", err)
+		}
+	}
+	for name, oper := range operStatusSettings {
+		if settings[name] == "" {
+			continue
+		}
+		policy.Oper[oper], err = netint.ParseState(settings[name])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %v state : %v. See usage for more details.", name, err)
+		}
+	}
+	if settings["expect-down"] != "" {
+		policy.ExpectDown, err = strconv.ParseBool(settings["expect-down"])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid expect-down value %v, must be true or false. See usage for more details.", settings["expect-down"])
+		}
+	}
+	return policy, nil
+}
@@ -0,0 +1,395 @@
+package ui
+
+/*
+go-shinken-check
+Copyright © 2020 pandaoc-io <nicolas.bertaina@gmail.com>
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-check-network-interface/netint"
+	"go-check-network-interface/profile"
+	"go-check-network-interface/threshold"
+)
+
+//Thresholds is used to transfert thresholds value to build the table HTML template
+type Thresholds struct {
+	In, Out DirectionThresholds
+	//Flaps are the limits of the number of pollings with a status change in the history
+	Flaps netint.Limits
+	//Dot3Errors are the limits of each error counter of the EtherLike-MIB (FCS, alignment...), disabled if not set
+	Dot3Errors netint.Limits
+	//Collisions are the limits of each collision counter of the EtherLike-MIB, disabled if not set
+	Collisions netint.Limits
+	//Optics are the limits of the measures of the optical transceivers, the thresholds of the transceiver if not set
+	Optics netint.OpticsLimits
+	//Lag are the limits of the capacity and of the unbalance of the members of the link aggregations
+	Lag netint.LagLimits
+}
+
+//DirectionThresholds are the thresholds of one direction of the interface
+type DirectionThresholds struct {
+	Bandwidth, Errors, Discards netint.Limits
+}
+
+//ThresholdFlags are the thresholds of one direction given in string format
+type ThresholdFlags struct {
+	Bw, Bc, Ew, Ec, Dw, Dc string
+}
+
+//bitRateRegex match a bit rate with its optional multiplier and unit (200M, 1.5Gbps...)
+var bitRateRegex = regexp.MustCompile(`^([0-9]*\.?[0-9]+)([kKMGT]?)(bps)?$`)
+
+var bitRateMultipliers = map[string]float64{"": 1, "k": 1e3, "K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12}
+
+//NewThresholds read and check the thresholds of each direction given in string format (80%, 50pps...).
+//The value of a threshold is a Nagios range ([@]start:end) followed by its unit (10%:, @10:20pps...),
+//the bandwidth thresholds can also be a bit rate in bps with a multiplier (150Mbps, 10M:, 1.5Gbps...)
+//and can be followed by the minimal duration of the condition (80%:15m, 50pps:5m...)
+func NewThresholds(in ThresholdFlags, out ThresholdFlags) (*Thresholds, error) {
+	t := &Thresholds{}
+	var err error
+	t.In, err = newDirectionThresholds("In", in)
+	if err != nil {
+		return nil, err
+	}
+	t.Out, err = newDirectionThresholds("Out", out)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+//Direction return the thresholds of the direction (in|out)
+func (t *Thresholds) Direction(direction string) DirectionThresholds {
+	if direction == "out" {
+		return t.Out
+	}
+	return t.In
+}
+
+//LongestDuration return the longest minimal duration of the enabled thresholds, the history must cover it
+func (t *Thresholds) LongestDuration() time.Duration {
+	all := []netint.Limits{t.In.Bandwidth, t.In.Errors, t.In.Discards, t.Out.Bandwidth, t.Out.Errors, t.Out.Discards,
+		t.Flaps, t.Dot3Errors, t.Collisions}
+	if !t.Lag.Disabled {
+		all = append(all, t.Lag.Capacity, t.Lag.Unbalance)
+	}
+	if !t.Optics.Disabled {
+		for _, limits := range t.Optics.Limits {
+			all = append(all, limits)
+		}
+	}
+	var longest time.Duration
+	for _, limits := range all {
+		if limits.Disabled {
+			continue
+		}
+		for _, limit := range []netint.Limit{limits.Warn, limits.Crit} {
+			if limit.Duration > longest {
+				longest = limit.Duration
+			}
+		}
+	}
+	return longest
+}
+
+//limits return the limits of the metric (bandwidth|errors|discards) for the direction (in|out)
+func (t *Thresholds) limits(metric string, direction string) netint.Limits {
+	dt := t.Direction(direction)
+	switch metric {
+	case "errors":
+		return dt.Errors
+	case "discards":
+		return dt.Discards
+	default:
+		return dt.Bandwidth
+	}
+}
+
+//Checks are the metrics tested against their thresholds which can be enabled with the checks setting
+var Checks = []string{"bandwidth", "errors", "discards", "flaps", "optics", "lag"}
+
+//DefaultChecks are the checks enabled by default, the optics need to walk the sensor tables of the device
+var DefaultChecks = []string{"bandwidth", "errors", "discards", "flaps", "lag"}
+
+//NewSettingsThresholds read and check the thresholds of the settings, the thresholds of a direction (bandwidth-in-warning...)
+//fall back to the thresholds of both directions (bandwidth-warning...) when empty.
+//The metrics missing from the comma separated list of the checks setting are disabled.
+func NewSettingsThresholds(settings profile.Settings) (*Thresholds, error) {
+	directionFlags := func(direction string) ThresholdFlags {
+		flag := func(metric string, level string) string {
+			if value := settings[metric+"-"+direction+"-"+level]; value != "" {
+				return value
+			}
+			return settings[metric+"-"+level]
+		}
+		return ThresholdFlags{
+			Bw: flag("bandwidth", "warning"),
+			Bc: flag("bandwidth", "critical"),
+			Ew: flag("error", "warning"),
+			Ec: flag("error", "critical"),
+			Dw: flag("discard", "warning"),
+			Dc: flag("discard", "critical"),
+		}
+	}
+	t, err := NewThresholds(directionFlags("in"), directionFlags("out"))
+	if err != nil {
+		return nil, err
+	}
+	warn, err := parseLimit(settings["flap-warning"], "")
+	if err != nil {
+		return nil, err
+	}
+	crit, err := parseLimit(settings["flap-critical"], "")
+	if err != nil {
+		return nil, err
+	}
+	t.Flaps = netint.Limits{Warn: *warn, Crit: *crit}
+	t.Dot3Errors, err = newOptionalLimits("Dot3 Error", settings["dot3-error-warning"], settings["dot3-error-critical"], []string{"pps", "%"}, "pps")
+	if err != nil {
+		return nil, err
+	}
+	t.Collisions, err = newOptionalLimits("Collision", settings["collision-warning"], settings["collision-critical"], []string{"pps", "%"}, "pps")
+	if err != nil {
+		return nil, err
+	}
+	t.Optics, err = newOpticsLimits(settings)
+	if err != nil {
+		return nil, err
+	}
+	t.Lag.Capacity, err = newLimits("Aggregation Capacity", settings["lag-capacity-warning"], settings["lag-capacity-critical"], []string{"%"}, "%")
+	if err != nil {
+		return nil, err
+	}
+	t.Lag.Unbalance, err = newOptionalLimits("Aggregation Unbalance", settings["lag-unbalance-warning"], settings["lag-unbalance-critical"], []string{"%"}, "%")
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool)
+	for _, check := range strings.Split(settings["checks"], ",") {
+		check = strings.TrimSpace(check)
+		if check == "" {
+			continue
+		}
+		if !isCheck(check) {
+			return nil, fmt.Errorf("Unknown check %v, must be one of %v. See usage for more details.", check, strings.Join(Checks, ", "))
+		}
+		enabled[check] = true
+	}
+	for _, dt := range []*DirectionThresholds{&t.In, &t.Out} {
+		dt.Bandwidth.Disabled = !enabled["bandwidth"]
+		dt.Errors.Disabled = !enabled["errors"]
+		dt.Discards.Disabled = !enabled["discards"]
+	}
+	t.Flaps.Disabled = !enabled["flaps"]
+	t.Dot3Errors.Disabled = t.Dot3Errors.Disabled || !enabled["errors"]
+	t.Optics.Disabled = !enabled["optics"]
+	t.Lag.Disabled = !enabled["lag"]
+	return t, nil
+}
+
+//opticsSettings are the settings of the limits of the measures of the optical transceivers, with their unit
+var opticsSettings = []struct{ label, setting, name, unit string }{
+	{"rx_power", "rx-power", "Rx Power", "dBm"},
+	{"tx_power", "tx-power", "Tx Power", "dBm"},
+	{"optics_temperature", "optics-temperature", "Optics Temperature", "C"},
+}
+
+//newOpticsLimits read the limits of the measures of the optical transceivers,
+//the measures without limits are tested against the thresholds of the transceiver
+func newOpticsLimits(settings profile.Settings) (netint.OpticsLimits, error) {
+	optics := netint.OpticsLimits{Limits: make(map[string]netint.Limits)}
+	for _, s := range opticsSettings {
+		limits, err := newOptionalLimits(s.name, settings[s.setting+"-warning"], settings[s.setting+"-critical"], []string{s.unit}, s.unit)
+		if err != nil {
+			return optics, err
+		}
+		if !limits.Disabled {
+			optics.Limits[s.label] = limits
+		}
+	}
+	return optics, nil
+}
+
+//newOptionalLimits read the warning and critical thresholds of a metric which isn't tested if they're empty
+func newOptionalLimits(name string, warnflag string, critflag string, units []string, defaultUnit string) (netint.Limits, error) {
+	if warnflag == "" && critflag == "" {
+		return netint.Limits{Disabled: true}, nil
+	}
+	if warnflag == "" || critflag == "" {
+		return netint.Limits{}, fmt.Errorf("%v thresholds must be both set or both empty. See usage for more details.", name)
+	}
+	return newLimits(name, warnflag, critflag, units, defaultUnit)
+}
+
+func isCheck(check string) bool {
+	for _, c := range Checks {
+		if c == check {
+			return true
+		}
+	}
+	return false
+}
+
+func newDirectionThresholds(direction string, flags ThresholdFlags) (DirectionThresholds, error) {
+	var dt DirectionThresholds
+	var err error
+	dt.Bandwidth, err = newLimits(direction+" Bandwidth", flags.Bw, flags.Bc, []string{"bps", "%"}, "")
+	if err != nil {
+		return dt, err
+	}
+	dt.Errors, err = newLimits(direction+" Error", flags.Ew, flags.Ec, []string{"pps", "%"}, "%")
+	if err != nil {
+		return dt, err
+	}
+	dt.Discards, err = newLimits(direction+" Discard", flags.Dw, flags.Dc, []string{"pps", "%"}, "%")
+	return dt, err
+}
+
+//newLimits read the warning and critical thresholds of a metric, both must have the same unit.
+//The defaultUnit is used when no unit is given, a unit is mandatory if it's empty.
+func newLimits(name string, warnflag string, critflag string, units []string, defaultUnit string) (netint.Limits, error) {
+	limits := netint.Limits{Unit: defaultUnit}
+	found := false
+	for _, unit := range units {
+		if strings.Contains(critflag, unit) || strings.Contains(warnflag, unit) {
+			limits.Unit, found = unit, true
+			break
+		}
+	}
+	if limits.Unit == "" || (found && (!strings.Contains(critflag, limits.Unit) || !strings.Contains(warnflag, limits.Unit))) {
+		if defaultUnit == "" {
+			return limits, fmt.Errorf("%v thresholds aren't both of type %v. See usage for more details.", name, strings.Join(units, " or "))
+		}
+		return limits, fmt.Errorf("%v thresholds haven't the same type. See usage for more details.", name)
+	}
+
+	warn, err := parseLimit(warnflag, limits.Unit)
+	if err != nil {
+		return limits, err
+	}
+	crit, err := parseLimit(critflag, limits.Unit)
+	if err != nil {
+		return limits, err
+	}
+	limits.Warn, limits.Crit = *warn, *crit
+	return limits, nil
+}
+
+//parseLimit read a threshold flag made of a range, its unit and its optional duration (80%:15m)
+func parseLimit(flag string, unit string) (*netint.Limit, error) {
+	value := flag
+	var duration time.Duration
+	//The last field is the duration only if it isn't the end of the range (10:20, 10:20pps)
+	if i := strings.LastIndex(value, ":"); i >= 0 {
+		if d, err := time.ParseDuration(value[i+1:]); err == nil && !isRangeBound(value[i+1:], unit) {
+			if d < 0 {
+				return nil, fmt.Errorf("Invalid duration of the threshold %v, must be a positive duration like 15m. See usage for more details.", flag)
+			}
+			value, duration = value[:i], d
+		}
+	}
+	if unit == "bps" {
+		var err error
+		if value, err = scaleBitRates(value); err != nil {
+			return nil, fmt.Errorf("Invalid threshold %v : %v. See usage for more details.", flag, err)
+		}
+	}
+	r, err := threshold.Parse(strings.ReplaceAll(value, unit, ""))
+	if err != nil {
+		return nil, fmt.Errorf("%v. See usage for more details.", err)
+	}
+	return &netint.Limit{Range: *r, Duration: duration}, nil
+}
+
+//isRangeBound check if the field is a number, with or without the unit of the threshold
+func isRangeBound(field string, unit string) bool {
+	if unit == "bps" {
+		_, err := parseBitRate(field)
+		return err == nil
+	}
+	_, err := strconv.ParseFloat(strings.TrimSuffix(field, unit), 64)
+	return err == nil
+}
+
+//scaleBitRates replace the bit rates with a multiplier of a range by their value in bps (10M:1G become 10000000:1000000000)
+func scaleBitRates(value string) (string, error) {
+	var prefix string
+	if strings.HasPrefix(value, "@") {
+		prefix, value = "@", value[1:]
+	}
+	bounds := strings.SplitN(value, ":", 2)
+	for i, bound := range bounds {
+		if bound == "" || bound == "~" {
+			continue
+		}
+		//Unit given after an open end (10M:bps)
+		if i == 1 && bound == "bps" {
+			bounds[i] = ""
+			continue
+		}
+		rate, err := parseBitRate(bound)
+		if err != nil {
+			return "", err
+		}
+		bounds[i] = strconv.FormatFloat(rate, 'f', -1, 64)
+	}
+	return prefix + strings.Join(bounds, ":"), nil
+}
+
+//parseBitRate read a bit rate with its optional multiplier and unit (200M, 1.5Gbps...) and return its value in bps
+func parseBitRate(value string) (float64, error) {
+	m := bitRateRegex.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf("%v isn't a bit rate", value)
+	}
+	rate, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("%v isn't a bit rate", value)
+	}
+	return rate * bitRateMultipliers[m[2]], nil
+}
+
+//NewSpeedOverride read the speed override given in string format, a single speed for both directions (200M)
+//or the in and out speeds separated by a slash (10M/1M)
+func NewSpeedOverride(flag string) (netint.SpeedOverride, error) {
+	var override netint.SpeedOverride
+	if flag == "" {
+		return override, nil
+	}
+	speeds := strings.Split(flag, "/")
+	if len(speeds) > 2 {
+		return override, fmt.Errorf("Invalid speed override %v, must be a speed or the in/out speeds (200M, 10M/1M). See usage for more details.", flag)
+	}
+	for i, speed := range speeds {
+		rate, err := parseBitRate(strings.TrimSpace(speed))
+		if err != nil || rate < 1 {
+			return override, fmt.Errorf("Invalid speed override %v, must be a speed or the in/out speeds (200M, 10M/1M). See usage for more details.", flag)
+		}
+		if i == 0 {
+			override.In = uint(rate)
+		}
+		override.Out = uint(rate)
+	}
+	return override, nil
+}